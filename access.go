@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/access"
+	"github.com/wisepythagoras/geoip-service/auth"
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+// accessPolicy holds the allow and deny lists of the route groups.
+var accessPolicy = access.NewController()
+
+// isRouteGroup returns true if the name is a route group that can have its own policy.
+func isRouteGroup(name string) bool {
+	switch name {
+	case groupIP, groupDomain, groupExtension, groupAdmin:
+		return true
+	}
+
+	return strings.HasPrefix(name, auth.ScopeExtensionPrefix) && len(name) > len(auth.ScopeExtensionPrefix)
+}
+
+// splitGroup splits a [group=]value definition. The group is empty if there isn't one.
+func splitGroup(def string) (string, string, error) {
+	group, value, found := strings.Cut(def, "=")
+
+	if !found {
+		return "", def, nil
+	}
+
+	if !isRouteGroup(group) {
+		return "", "", fmt.Errorf("unknown route group %q (expected ip, domain, ext, ext:<name>, or admin)", group)
+	}
+
+	return group, value, nil
+}
+
+// addAccessFlags registers the flags of the allow and deny lists and returns a function that
+// adds the lists to the access policy once they are parsed. Each list can be limited to a route
+// group by prefixing it with the group's name (e.g. -deny-list ext=./blocked.txt).
+func addAccessFlags(fs *flag.FlagSet) func() error {
+	whitelist := fs.String("whitelist", "", "A file with the IPs and CIDR ranges that are allowed to access the API (same as -allow-list)")
+	allowLists := &listFlag{}
+	fs.Var(allowLists, "allow-list", "A file with the IPs and CIDR ranges allowed to access the API, as [group=]path (can be repeated)")
+	denyLists := &listFlag{}
+	fs.Var(denyLists, "deny-list", "A file with the IPs and CIDR ranges denied access to the API, as [group=]path (can be repeated)")
+	allowEntries := &listFlag{}
+	fs.Var(allowEntries, "allow", "An IP address or CIDR range allowed to access the API, as [group=]entry (can be repeated)")
+	denyEntries := &listFlag{}
+	fs.Var(denyEntries, "deny", "An IP address or CIDR range denied access to the API, as [group=]entry (can be repeated)")
+
+	return func() error {
+		if len(*whitelist) > 0 {
+			allowLists.values = append(allowLists.values, *whitelist)
+		}
+
+		for _, def := range allowLists.values {
+			group, path, err := splitGroup(def)
+
+			if err != nil {
+				return err
+			}
+
+			policy := accessPolicy.Policy(group)
+			policy.AllowFiles = append(policy.AllowFiles, path)
+		}
+
+		for _, def := range denyLists.values {
+			group, path, err := splitGroup(def)
+
+			if err != nil {
+				return err
+			}
+
+			policy := accessPolicy.Policy(group)
+			policy.DenyFiles = append(policy.DenyFiles, path)
+		}
+
+		for _, def := range allowEntries.values {
+			group, entry, err := splitGroup(def)
+
+			if err != nil {
+				return err
+			}
+
+			policy := accessPolicy.Policy(group)
+			policy.AllowEntries = append(policy.AllowEntries, entry)
+		}
+
+		for _, def := range denyEntries.values {
+			group, entry, err := splitGroup(def)
+
+			if err != nil {
+				return err
+			}
+
+			policy := accessPolicy.Policy(group)
+			policy.DenyEntries = append(policy.DenyEntries, entry)
+		}
+
+		return accessPolicy.Load()
+	}
+}
+
+// checkAccess makes sure that the client is allowed to use the route by the access policy, and
+// otherwise aborts the request with the reason. The health checks and the API docs are exempt, as
+// they are from the geo rules.
+func checkAccess(c *gin.Context) bool {
+	if isServiceRoute(c.FullPath()) {
+		return true
+	}
+
+	group, name := routeGroup(c.FullPath())
+	groups := []string{}
+
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+
+	if name != group {
+		groups = append(groups, name)
+	}
+
+	allowed, reason := accessPolicy.Check(requestClientIP(c), groups...)
+
+	if !allowed {
+		c.AbortWithStatusJSON(403, &types.ApiResponse{
+			Success: false,
+			Status:  "Access denied: " + reason,
+		})
+	}
+
+	return allowed
+}
@@ -0,0 +1,301 @@
+package access
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Policy is the allow and deny list of a route group. The lists are made of files, which are
+// read again when they change, and of entries given inline.
+type Policy struct {
+	AllowFiles   []string
+	DenyFiles    []string
+	AllowEntries []string
+	DenyEntries  []string
+}
+
+// compiled is a policy with its lists loaded into tries.
+type compiled struct {
+	allow *Trie
+	deny  *Trie
+}
+
+// ParseEntry parses an IP address or CIDR range.
+func ParseEntry(entry string) (*net.IPNet, error) {
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+
+		bits := len(ip) * 8
+
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, ipRange, err := net.ParseCIDR(entry)
+
+	return ipRange, err
+}
+
+// ReadList adds the IP addresses and CIDR ranges in a list, one per line, to the trie. Anything
+// after a # is a comment.
+func ReadList(r io.Reader, trie *Trie) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+
+		if len(line) == 0 {
+			continue
+		}
+
+		ipRange, err := ParseEntry(line)
+
+		if err != nil {
+			return err
+		}
+
+		trie.Insert(ipRange)
+	}
+
+	return scanner.Err()
+}
+
+// readFiles builds a trie out of the entries and the lists in the files.
+func readFiles(files, entries []string) (*Trie, error) {
+	if len(files) == 0 && len(entries) == 0 {
+		return nil, nil
+	}
+
+	trie := NewTrie()
+
+	for _, entry := range entries {
+		ipRange, err := ParseEntry(entry)
+
+		if err != nil {
+			return nil, err
+		}
+
+		trie.Insert(ipRange)
+	}
+
+	for _, path := range files {
+		file, err := os.Open(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = ReadList(file, trie)
+		file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return trie, nil
+}
+
+// Controller decides which clients can use which routes. Its policies are keyed by route group,
+// and the one under the empty group applies to every route.
+type Controller struct {
+	Policies map[string]*Policy
+	lock     sync.RWMutex
+	// OnReload is called after the reloads that Watch triggers, with what triggered them. It's
+	// optional.
+	OnReload func(trigger string, err error)
+	compiled map[string]*compiled
+	modTimes map[string]time.Time
+}
+
+// NewController creates a controller without any policies, which allows everything.
+func NewController() *Controller {
+	return &Controller{Policies: make(map[string]*Policy)}
+}
+
+// Policy returns the policy of a group, creating it if needed.
+func (c *Controller) Policy(group string) *Policy {
+	if c.Policies[group] == nil {
+		c.Policies[group] = &Policy{}
+	}
+
+	return c.Policies[group]
+}
+
+// IsEmpty returns true if there are no policies.
+func (c *Controller) IsEmpty() bool {
+	return len(c.Policies) == 0
+}
+
+// readModTimes returns the modification time of every list file. The files that are missing get
+// the zero time, so that they only count as changed once they're back.
+func (c *Controller) readModTimes() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+
+	for _, policy := range c.Policies {
+		for _, path := range append(append([]string{}, policy.AllowFiles...), policy.DenyFiles...) {
+			modTimes[path] = time.Time{}
+
+			if info, err := os.Stat(path); err == nil {
+				modTimes[path] = info.ModTime()
+			}
+		}
+	}
+
+	return modTimes
+}
+
+// Load reads the lists and swaps them in. If any of them can't be read, the current ones are
+// kept. Either way the files aren't read again until they change, so a broken file is only
+// reported once.
+func (c *Controller) Load() error {
+	modTimes := c.readModTimes()
+	policies, err := c.compile()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.modTimes = modTimes
+
+	if err != nil {
+		return err
+	}
+
+	c.compiled = policies
+
+	return nil
+}
+
+// compile reads the lists of every policy into tries.
+func (c *Controller) compile() (map[string]*compiled, error) {
+	policies := make(map[string]*compiled)
+
+	for group, policy := range c.Policies {
+		allow, err := readFiles(policy.AllowFiles, policy.AllowEntries)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deny, err := readFiles(policy.DenyFiles, policy.DenyEntries)
+
+		if err != nil {
+			return nil, err
+		}
+
+		policies[group] = &compiled{allow: allow, deny: deny}
+	}
+
+	return policies, nil
+}
+
+// Check decides whether the client can use a route in the given groups, in addition to the
+// policy that applies to every route. The client needs to pass every policy that applies: it
+// must not be in the deny list, and it must be in the allow list if there is one. If it's denied,
+// the reason is returned.
+func (c *Controller) Check(ip net.IP, groups ...string) (bool, string) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, group := range append([]string{""}, groups...) {
+		policy, found := c.compiled[group]
+
+		if !found {
+			continue
+		}
+
+		name := "the global"
+
+		if len(group) > 0 {
+			name = fmt.Sprintf("the %s", group)
+		}
+
+		if policy.deny.Contains(ip) {
+			return false, fmt.Sprintf("%s is in %s deny list", ip, name)
+		}
+
+		if policy.allow != nil && !policy.allow.Contains(ip) {
+			return false, fmt.Sprintf("%s is not in %s allow list", ip, name)
+		}
+	}
+
+	return true, ""
+}
+
+// hasChanged returns true if any of the list files were modified since they were read.
+func (c *Controller) hasChanged() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	modTimes := c.readModTimes()
+
+	if len(modTimes) != len(c.modTimes) {
+		return true
+	}
+
+	for path, modTime := range modTimes {
+		if !modTime.Equal(c.modTimes[path]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Watch reloads the lists when the process receives a SIGHUP, and when the files change if
+// interval isn't 0.
+func (c *Controller) Watch(interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var ticks <-chan time.Time
+
+	if interval > 0 {
+		ticks = time.NewTicker(interval).C
+	}
+
+	go func() {
+		for {
+			trigger := "SIGHUP"
+
+			select {
+			case <-signals:
+			case <-ticks:
+				if !c.hasChanged() {
+					continue
+				}
+
+				trigger = "file change"
+			}
+
+			err := c.Load()
+
+			if err != nil {
+				log.Println("Unable to reload the access lists:", err)
+			} else {
+				log.Println("Reloaded the access lists")
+			}
+
+			if c.OnReload != nil {
+				c.OnReload(trigger, err)
+			}
+		}
+	}()
+}
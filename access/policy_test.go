@@ -0,0 +1,99 @@
+package access
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	c := NewController()
+	c.Policy("").DenyEntries = []string{"203.0.113.0/24"}
+	c.Policy("admin").AllowEntries = []string{"10.0.0.0/8"}
+	c.Policy("ext:whois").DenyEntries = []string{"10.9.0.0/16"}
+
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip      string
+		groups  []string
+		allowed bool
+	}{
+		{"192.0.2.1", nil, true},
+		{"203.0.113.5", nil, false},
+		{"203.0.113.5", []string{"admin"}, false},
+		{"10.1.1.1", []string{"admin"}, true},
+		{"192.0.2.1", []string{"admin"}, false},
+		{"10.9.1.1", []string{"admin"}, true},
+		{"10.9.1.1", []string{"ext", "ext:whois"}, false},
+		{"192.0.2.1", []string{"lookup"}, true},
+	}
+
+	for _, test := range tests {
+		allowed, reason := c.Check(net.ParseIP(test.ip), test.groups...)
+
+		if allowed != test.allowed {
+			t.Errorf("Check(%s, %v) = %v (%s), expected %v", test.ip, test.groups, allowed, reason, test.allowed)
+		}
+
+		if !allowed && len(reason) == 0 {
+			t.Errorf("Check(%s, %v) didn't give a reason", test.ip, test.groups)
+		}
+	}
+}
+
+func TestLoadKeepsListsOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allow.txt")
+
+	if err := os.WriteFile(path, []byte("10.0.0.0/8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewController()
+	c.Policy("").AllowFiles = []string{path}
+
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.hasChanged() {
+		t.Error("expected the lists not to have changed right after loading them")
+	}
+
+	// The modification time is moved forward, as the file may be written within the same tick.
+	if err := os.WriteFile(path, []byte("not an address\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	if !c.hasChanged() {
+		t.Fatal("expected the file to have changed")
+	}
+
+	if err := c.Load(); err == nil {
+		t.Fatal("expected an error for the broken list")
+	}
+
+	if allowed, _ := c.Check(net.ParseIP("10.1.1.1")); !allowed {
+		t.Error("expected the previous list to be kept")
+	}
+
+	// The broken file is only reported once.
+	if c.hasChanged() {
+		t.Error("expected the broken file not to count as changed again")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.hasChanged() {
+		t.Error("expected the missing file to count as changed")
+	}
+}
@@ -0,0 +1,96 @@
+package access
+
+import "net"
+
+type trieNode struct {
+	children [2]*trieNode
+	// terminal is set when a range ends at this node, so every address below it matches.
+	terminal bool
+}
+
+// Trie is a binary prefix trie of CIDR ranges. A lookup walks at most one node per bit of the
+// address, so it takes the same time no matter how many ranges the trie holds.
+type Trie struct {
+	v4  *trieNode
+	v6  *trieNode
+	len int
+}
+
+// NewTrie creates an empty trie.
+func NewTrie() *Trie {
+	return &Trie{v4: &trieNode{}, v6: &trieNode{}}
+}
+
+// normalize returns the address in its 4 byte form if it's an IPv4 address, along with the root
+// of its family.
+func (t *Trie) normalize(ip net.IP) (net.IP, *trieNode) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, t.v4
+	}
+
+	return ip.To16(), t.v6
+}
+
+// Insert adds a range to the trie.
+func (t *Trie) Insert(ipRange *net.IPNet) {
+	ip, node := t.normalize(ipRange.IP)
+	ones, _ := ipRange.Mask.Size()
+
+	// IPv4 ranges written in their IPv6 form (::ffff:0:0/96) count the mapping prefix.
+	if len(ip) == net.IPv4len && len(ipRange.Mask) == net.IPv6len {
+		ones = max(ones-96, 0)
+	}
+
+	for i := 0; i < ones && !node.terminal; i++ {
+		bit := (ip[i/8] >> (7 - i%8)) & 1
+
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+
+		node = node.children[bit]
+	}
+
+	node.terminal = true
+	node.children = [2]*trieNode{}
+	t.len++
+}
+
+// InsertIP adds a single address to the trie.
+func (t *Trie) InsertIP(ip net.IP) {
+	ip, _ = t.normalize(ip)
+	bits := len(ip) * 8
+	t.Insert(&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+}
+
+// Contains returns true if the address is in any of the ranges.
+func (t *Trie) Contains(ip net.IP) bool {
+	if t == nil || ip == nil {
+		return false
+	}
+
+	ip, node := t.normalize(ip)
+
+	for i := 0; i < len(ip)*8; i++ {
+		if node.terminal {
+			return true
+		}
+
+		node = node.children[(ip[i/8]>>(7-i%8))&1]
+
+		if node == nil {
+			return false
+		}
+	}
+
+	return node.terminal
+}
+
+// Len returns the number of ranges that were inserted.
+func (t *Trie) Len() int {
+	if t == nil {
+		return 0
+	}
+
+	return t.len
+}
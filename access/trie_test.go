@@ -0,0 +1,174 @@
+package access
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestTrieContains(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		inside  []string
+		outside []string
+	}{
+		{
+			name:    "empty",
+			outside: []string{"0.0.0.0", "10.0.0.1", "::", "2001:db8::1"},
+		},
+		{
+			name:    "single address",
+			entries: []string{"192.0.2.1"},
+			inside:  []string{"192.0.2.1", "::ffff:192.0.2.1"},
+			outside: []string{"192.0.2.0", "192.0.2.2", "::192.0.2.1"},
+		},
+		{
+			name:    "IPv4 range",
+			entries: []string{"10.0.0.0/8"},
+			inside:  []string{"10.0.0.0", "10.1.2.3", "10.255.255.255"},
+			outside: []string{"9.255.255.255", "11.0.0.0"},
+		},
+		{
+			name:    "range boundaries off the byte edge",
+			entries: []string{"172.16.0.0/12"},
+			inside:  []string{"172.16.0.0", "172.31.255.255"},
+			outside: []string{"172.15.255.255", "172.32.0.0"},
+		},
+		{
+			name:    "narrower range inside a broader one",
+			entries: []string{"10.1.0.0/16", "10.0.0.0/8"},
+			inside:  []string{"10.1.2.3", "10.2.0.1"},
+			outside: []string{"11.1.2.3"},
+		},
+		{
+			name:    "broader range first",
+			entries: []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.3"},
+			inside:  []string{"10.1.2.3", "10.200.0.1"},
+			outside: []string{"12.0.0.1"},
+		},
+		{
+			name:    "sibling ranges",
+			entries: []string{"192.0.2.0/25", "192.0.2.128/26"},
+			inside:  []string{"192.0.2.0", "192.0.2.127", "192.0.2.128", "192.0.2.191"},
+			outside: []string{"192.0.2.192", "192.0.2.255"},
+		},
+		{
+			name:    "everything",
+			entries: []string{"0.0.0.0/0"},
+			inside:  []string{"0.0.0.0", "255.255.255.255", "8.8.8.8"},
+			outside: []string{"2001:db8::1"},
+		},
+		{
+			name:    "IPv6 range",
+			entries: []string{"2001:db8::/32"},
+			inside:  []string{"2001:db8::1", "2001:db8:ffff::"},
+			outside: []string{"2001:db9::1", "32.1.13.184"},
+		},
+		{
+			name:    "IPv4 range in its IPv6 form",
+			entries: []string{"::ffff:10.0.0.0/104"},
+			inside:  []string{"10.0.0.1", "10.255.0.1"},
+			outside: []string{"11.0.0.1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trie := NewTrie()
+
+			for _, entry := range test.entries {
+				ipRange, err := ParseEntry(entry)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				trie.Insert(ipRange)
+			}
+
+			if trie.Len() != len(test.entries) {
+				t.Errorf("Len() = %d, expected %d", trie.Len(), len(test.entries))
+			}
+
+			for _, addr := range test.inside {
+				if !trie.Contains(net.ParseIP(addr)) {
+					t.Errorf("expected %s to be in the trie", addr)
+				}
+			}
+
+			for _, addr := range test.outside {
+				if trie.Contains(net.ParseIP(addr)) {
+					t.Errorf("expected %s not to be in the trie", addr)
+				}
+			}
+		})
+	}
+}
+
+func TestTrieNil(t *testing.T) {
+	var trie *Trie
+
+	if trie.Contains(net.ParseIP("10.0.0.1")) || trie.Len() != 0 {
+		t.Error("expected a nil trie to be empty")
+	}
+
+	trie = NewTrie()
+	trie.InsertIP(net.ParseIP("10.0.0.1"))
+
+	if trie.Contains(nil) {
+		t.Error("expected a nil address not to be in the trie")
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		entry    string
+		expected string
+	}{
+		{"192.0.2.1", "192.0.2.1/32"},
+		{"192.0.2.1/24", "192.0.2.0/24"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"2001:db8::/32", "2001:db8::/32"},
+		{"192.0.2", ""},
+		{"192.0.2.0/33", ""},
+		{"example.com", ""},
+	}
+
+	for _, test := range tests {
+		ipRange, err := ParseEntry(test.entry)
+
+		if len(test.expected) == 0 {
+			if err == nil {
+				t.Errorf("ParseEntry(%q) = %v, expected an error", test.entry, ipRange)
+			}
+		} else if err != nil {
+			t.Errorf("ParseEntry(%q) failed: %v", test.entry, err)
+		} else if ipRange.String() != test.expected {
+			t.Errorf("ParseEntry(%q) = %v, expected %s", test.entry, ipRange, test.expected)
+		}
+	}
+}
+
+func TestReadList(t *testing.T) {
+	list := "# The office\n10.0.0.0/8\n\n  192.0.2.1  # A single host\n2001:db8::/32\n"
+	trie := NewTrie()
+
+	if err := ReadList(strings.NewReader(list), trie); err != nil {
+		t.Fatal(err)
+	}
+
+	if trie.Len() != 3 {
+		t.Errorf("expected 3 ranges, got %d", trie.Len())
+	}
+
+	for _, addr := range []string{"10.1.1.1", "192.0.2.1", "2001:db8::5"} {
+		if !trie.Contains(net.ParseIP(addr)) {
+			t.Errorf("expected %s to be in the list", addr)
+		}
+	}
+
+	if err := ReadList(strings.NewReader("10.0.0.0/8\nnot an address\n"), NewTrie()); err == nil {
+		t.Error("expected an error for an invalid line")
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/wisepythagoras/geoip-service/crypto"
+)
+
+const (
+	// ScopeLookup allows the IP and domain lookups.
+	ScopeLookup = "lookup"
+	// ScopeAdmin allows the admin endpoints, and includes every other scope.
+	ScopeAdmin = "admin"
+	// ScopeExtensionPrefix prefixes the scopes of the extensions' endpoints (e.g. ext:blocklist).
+	// ext:* allows the endpoints of every extension.
+	ScopeExtensionPrefix = "ext:"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid API key")
+	ErrExpiredKey = errors.New("expired API key")
+	ErrKeyExists  = errors.New("a key with this name already exists")
+	ErrNoSuchKey  = errors.New("there is no key with this name")
+)
+
+// Key is an API key. Only the hash of the token is kept, so the token itself can't be recovered
+// once it's created.
+type Key struct {
+	Name         string     `json:"name" gorm:"primaryKey"`
+	Hash         string     `json:"hash" gorm:"uniqueIndex"`
+	Scopes       []string   `json:"scopes" gorm:"serializer:json"`
+	AllowedCIDRs []string   `json:"allowed_cidrs,omitempty" gorm:"serializer:json"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// ValidateScope returns an error if the scope isn't one we know of.
+func ValidateScope(scope string) error {
+	if scope == ScopeLookup || scope == ScopeAdmin {
+		return nil
+	}
+
+	if strings.HasPrefix(scope, ScopeExtensionPrefix) && len(scope) > len(ScopeExtensionPrefix) {
+		return nil
+	}
+
+	return fmt.Errorf("unknown scope %q", scope)
+}
+
+// ScopeMatches returns true if the granted scope covers the required one. An empty required scope
+// is covered by anything.
+func ScopeMatches(granted, required string) bool {
+	if required == "" || granted == ScopeAdmin || granted == required {
+		return true
+	}
+
+	return granted == ScopeExtensionPrefix+"*" && strings.HasPrefix(required, ScopeExtensionPrefix)
+}
+
+// HashToken returns the hash of a token, which is what the stores keep.
+func HashToken(token string) string {
+	hash, _ := crypto.GetSHA256Hash([]byte(token))
+	return crypto.ByteArrayToHex(hash)
+}
+
+// NewToken generates a new random token (see crypto.GenToken for the format).
+func NewToken() (string, error) {
+	return crypto.GenToken()
+}
+
+// NewKey creates a key with a new token, which is returned along with it. A ttl of 0 means that
+// the key never expires.
+func NewKey(name string, scopes, allowedCIDRs []string, ttl time.Duration) (*Key, string, error) {
+	if len(name) == 0 {
+		return nil, "", errors.New("the key needs a name")
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", errors.New("the key needs at least one scope")
+	}
+
+	for _, scope := range scopes {
+		if err := ValidateScope(scope); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, cidr := range allowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, "", err
+		}
+	}
+
+	token, err := NewToken()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &Key{
+		Name:         name,
+		Hash:         HashToken(token),
+		Scopes:       scopes,
+		AllowedCIDRs: allowedCIDRs,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	if ttl > 0 {
+		expiresAt := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &expiresAt
+	}
+
+	return key, token, nil
+}
+
+// HasScope returns true if any of the key's scopes covers the required one.
+func (k *Key) HasScope(required string) bool {
+	for _, scope := range k.Scopes {
+		if ScopeMatches(scope, required) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsExpired returns true if the key has an expiry date that has passed.
+func (k *Key) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// AllowsIP returns true if the key can be used from the given IP address. Keys without allowed
+// CIDRs can be used from anywhere.
+func (k *Key) AllowsIP(ip net.IP) bool {
+	if len(k.AllowedCIDRs) == 0 {
+		return true
+	}
+
+	for _, cidr := range k.AllowedCIDRs {
+		if _, ipRange, err := net.ParseCIDR(cidr); err == nil && ipRange.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/wisepythagoras/geoip-service/crypto"
+)
+
+// Keyring authenticates tokens against the keys in a store, along with keys that only live in
+// memory (such as the one passed with -api-key).
+type Keyring struct {
+	Store  Store
+	Static []*Key
+}
+
+// AddStatic adds an in-memory key for the given token.
+func (k *Keyring) AddStatic(name, token string, scopes ...string) {
+	k.Static = append(k.Static, &Key{
+		Name:   name,
+		Hash:   HashToken(token),
+		Scopes: scopes,
+	})
+}
+
+// Authenticate returns the key that the token belongs to.
+func (k *Keyring) Authenticate(token string) (*Key, error) {
+	if k == nil || len(token) == 0 {
+		return nil, ErrInvalidKey
+	}
+
+	// The generated tokens carry a checksum, so mistyped or made up ones can be turned away
+	// without going to the store. Tokens without the prefix may have been passed with -api-key.
+	if strings.HasPrefix(token, crypto.TokenPrefix) && !crypto.IsValidToken(token) {
+		return nil, ErrInvalidKey
+	}
+
+	hash := HashToken(token)
+	var key *Key
+
+	// Every static key is compared, so the time it takes doesn't depend on which one matched.
+	for _, static := range k.Static {
+		if crypto.EqualStrings(static.Hash, hash) && key == nil {
+			key = static
+		}
+	}
+
+	if key == nil && k.Store != nil {
+		found, err := k.Store.Find(hash)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key = found
+	}
+
+	if key == nil {
+		return nil, ErrInvalidKey
+	}
+
+	if key.IsExpired() {
+		return nil, ErrExpiredKey
+	}
+
+	return key, nil
+}
+
+// Close closes the store.
+func (k *Keyring) Close() error {
+	if k == nil || k.Store == nil {
+		return nil
+	}
+
+	return k.Store.Close()
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wisepythagoras/geoip-service/crypto"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Store keeps the API keys.
+type Store interface {
+	// Find returns the key with the given token hash, or nil if there isn't one.
+	Find(hash string) (*Key, error)
+	List() ([]*Key, error)
+	Add(key *Key) error
+	Revoke(name string) error
+	Close() error
+}
+
+// OpenStore opens the key store at the given path. Files ending in .db, .sqlite, or .sqlite3 are
+// SQLite databases, and anything else is a JSON file.
+func OpenStore(path string) (Store, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return OpenSQLiteStore(path)
+	}
+
+	return &FileStore{Path: path}, nil
+}
+
+// FileStore keeps the keys in a JSON file. The file is read again whenever it changes, so keys
+// that are created or revoked by another process take effect right away.
+type FileStore struct {
+	Path    string
+	lock    sync.Mutex
+	keys    []*Key
+	modTime time.Time
+}
+
+// load reads the file if it changed since it was last read. It must be called with the lock held.
+func (s *FileStore) load() error {
+	info, err := os.Stat(s.Path)
+
+	if os.IsNotExist(err) {
+		s.keys = nil
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if s.keys != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.Path)
+
+	if err != nil {
+		return err
+	}
+
+	keys := []*Key{}
+
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+
+	s.keys = keys
+	s.modTime = info.ModTime()
+
+	return nil
+}
+
+// save writes the keys to a temporary file and renames it over the store, so that readers never
+// see a partial file. It must be called with the lock held.
+func (s *FileStore) save(keys []*Key) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.Path)
+	file, err := os.CreateTemp(dir, filepath.Base(s.Path)+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	// The store only holds hashes, but there is no reason for anyone else to read it.
+	if err = os.Chmod(file.Name(), 0600); err != nil {
+		return err
+	}
+
+	if err = os.Rename(file.Name(), s.Path); err != nil {
+		return err
+	}
+
+	// Force the next load to read the file again.
+	s.keys = nil
+
+	return nil
+}
+
+func (s *FileStore) Find(hash string) (*Key, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	var found *Key
+
+	for _, key := range s.keys {
+		if crypto.EqualStrings(key.Hash, hash) && found == nil {
+			found = key
+		}
+	}
+
+	return found, nil
+}
+
+func (s *FileStore) List() ([]*Key, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	keys := append([]*Key{}, s.keys...)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+
+	return keys, nil
+}
+
+func (s *FileStore) Add(key *Key) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	for _, existing := range s.keys {
+		if existing.Name == key.Name {
+			return ErrKeyExists
+		}
+	}
+
+	return s.save(append(append([]*Key{}, s.keys...), key))
+}
+
+func (s *FileStore) Revoke(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	keys := []*Key{}
+
+	for _, key := range s.keys {
+		if key.Name != name {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == len(s.keys) {
+		return ErrNoSuchKey
+	}
+
+	return s.save(keys)
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// SQLiteStore keeps the keys in a SQLite database.
+type SQLiteStore struct {
+	db *gorm.DB
+}
+
+// OpenSQLiteStore opens (or creates) the SQLite database at the given path.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := gorm.Open(sqlite.Open("file:"+path), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.AutoMigrate(&Key{}); err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Find(hash string) (*Key, error) {
+	// The index is searched by the hash and not the token, so how long the query takes says
+	// nothing useful about the token.
+	keys := []*Key{}
+
+	if err := s.db.Where("hash = ?", hash).Limit(1).Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	return keys[0], nil
+}
+
+func (s *SQLiteStore) List() ([]*Key, error) {
+	keys := []*Key{}
+	err := s.db.Order("name").Find(&keys).Error
+
+	return keys, err
+}
+
+func (s *SQLiteStore) Add(key *Key) error {
+	var count int64
+
+	if err := s.db.Model(&Key{}).Where("name = ?", key.Name).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return ErrKeyExists
+	}
+
+	return s.db.Create(key).Error
+}
+
+func (s *SQLiteStore) Revoke(name string) error {
+	tx := s.db.Where("name = ?", name).Delete(&Key{})
+
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return ErrNoSuchKey
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	db, err := s.db.DB()
+
+	if err != nil {
+		return err
+	}
+
+	return db.Close()
+}
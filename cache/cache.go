@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type entry[V any] struct {
+	key       string
+	value     V
+	size      int
+	expiresAt time.Time
+}
+
+// Stats holds the counters of a cache.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Size      int    `json:"size"`
+	MaxSize   int    `json:"max_size"`
+}
+
+// Cache is an LRU cache bounded by the approximate memory its entries take up, where each entry
+// also expires after its own TTL. A nil cache is valid and never holds anything, so callers
+// don't need to check whether caching is enabled.
+type Cache[V any] struct {
+	lock      sync.Mutex
+	maxSize   int
+	size      int
+	items     map[string]*list.Element
+	order     *list.List
+	sizeOf    func(key string, value V) int
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// New creates a cache that holds up to maxSize bytes, as estimated by sizeOf.
+func New[V any](maxSize int, sizeOf func(key string, value V) int) *Cache[V] {
+	return &Cache[V]{
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		order:   list.New(),
+		sizeOf:  sizeOf,
+	}
+}
+
+// Get returns the value stored under the key, if it's there and hasn't expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	var zero V
+
+	if c == nil {
+		return zero, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	el, ok := c.items[key]
+
+	if !ok {
+		c.misses.Add(1)
+		return zero, false
+	}
+
+	e := el.Value.(*entry[V])
+
+	if time.Now().After(e.expiresAt) {
+		c.remove(el)
+		c.misses.Add(1)
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+	c.hits.Add(1)
+
+	return e.value, true
+}
+
+// Set stores a value for the given TTL, evicting the least recently used entries if the cache
+// would go over its size. Values larger than the whole cache aren't stored.
+func (c *Cache[V]) Set(key string, value V, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+
+	size := c.sizeOf(key, value)
+
+	if size > c.maxSize {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	for c.size+size > c.maxSize && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+
+	c.items[key] = c.order.PushFront(&entry[V]{
+		key:       key,
+		value:     value,
+		size:      size,
+		expiresAt: time.Now().Add(ttl),
+	})
+	c.size += size
+}
+
+// Purge removes every entry.
+func (c *Cache[V]) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.size = 0
+}
+
+// Stats returns the cache's counters.
+func (c *Cache[V]) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   c.order.Len(),
+		Size:      c.size,
+		MaxSize:   c.maxSize,
+	}
+}
+
+// remove deletes an entry. The lock must be held.
+func (c *Cache[V]) remove(el *list.Element) {
+	e := c.order.Remove(el).(*entry[V])
+	delete(c.items, e.key)
+	c.size -= e.size
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/cors"
+)
+
+// corsConfig is the server's CORS policy, and extensionCORS holds the policies of the extensions
+// that override it, keyed by the extension's name.
+var corsConfig = &cors.Config{}
+var extensionCORS = map[string]*cors.Config{}
+
+// splitList splits a comma separated list, dropping the empty entries.
+func splitList(list string) []string {
+	values := []string{}
+
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); len(value) > 0 {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// addCORSFlags registers the flags of the CORS policy and returns a function that sets it up
+// once they are parsed, along with the overrides of the extensions.
+func addCORSFlags(fs *flag.FlagSet) func() error {
+	origins := fs.String("cors-origins", "", "A comma separated list of the origins allowed to call the API from a browser, e.g. https://*.example.com (CORS is disabled if empty)")
+	methods := fs.String("cors-methods", "GET,POST", "A comma separated list of the methods allowed in CORS requests")
+	headers := fs.String("cors-headers", "Accept-Language,Content-Type,X-AUTH-TOKEN", "A comma separated list of the headers allowed in CORS requests (* allows any)")
+	credentials := fs.Bool("cors-credentials", false, "Allow CORS requests with credentials (cookies or client certificates)")
+	maxAge := fs.Duration("cors-max-age", 10*time.Minute, "How long browsers can cache the result of a CORS preflight request")
+
+	return func() error {
+		corsConfig = &cors.Config{
+			Origins:     splitList(*origins),
+			Methods:     splitList(*methods),
+			Headers:     splitList(*headers),
+			Credentials: *credentials,
+			MaxAge:      int(maxAge.Seconds()),
+		}
+
+		if err := corsConfig.Validate(); err != nil {
+			return err
+		}
+
+		for _, ext := range extensions {
+			if config := ext.CORS(); config != nil {
+				merged := config.Merge(*corsConfig)
+
+				if err := merged.Validate(); err != nil {
+					return fmt.Errorf("extension %q: %w", ext.Name(), err)
+				}
+
+				extensionCORS[ext.Name()] = &merged
+			}
+		}
+
+		return nil
+	}
+}
+
+// corsConfigFor returns the CORS policy of a path. The path is used rather than the route, since
+// preflight requests don't match any route.
+func corsConfigFor(path string) *cors.Config {
+	for name, config := range extensionCORS {
+		prefix := "/api/" + name
+
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return config
+		}
+	}
+
+	return corsConfig
+}
+
+// corsMiddleware sets the CORS headers, and answers the preflight requests.
+func corsMiddleware(c *gin.Context) {
+	config := corsConfigFor(c.Request.URL.Path)
+
+	if !config.IsEnabled() {
+		c.Next()
+		return
+	}
+
+	if config.Handle(c.Writer, c.Request) {
+		c.AbortWithStatus(204)
+		return
+	}
+
+	c.Next()
+}
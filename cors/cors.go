@@ -0,0 +1,130 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Config is a CORS policy. Origins can be exact (https://example.com), cover every subdomain
+// (https://*.example.com), or be * to allow any origin.
+type Config struct {
+	Origins     []string `json:"origins"`
+	Methods     []string `json:"methods"`
+	Headers     []string `json:"headers"`
+	Credentials bool     `json:"credentials"`
+	// MaxAge is how long (in seconds) browsers can cache the result of a preflight request.
+	MaxAge int `json:"maxAge"`
+}
+
+// IsEnabled returns true if any origin is allowed.
+func (c *Config) IsEnabled() bool {
+	return c != nil && len(c.Origins) > 0
+}
+
+// Validate checks that the policy doesn't let any origin make requests with credentials, since
+// that would let every website use the client's cookies or certificates.
+func (c *Config) Validate() error {
+	if c.Credentials && c.allowsAnyOrigin() {
+		return errors.New("credentials can't be allowed for any origin (*), list the origins instead")
+	}
+
+	return nil
+}
+
+// Merge returns the policy with its unset methods, headers, and max age taken from base.
+func (c Config) Merge(base Config) Config {
+	if len(c.Methods) == 0 {
+		c.Methods = base.Methods
+	}
+
+	if len(c.Headers) == 0 {
+		c.Headers = base.Headers
+	}
+
+	if c.MaxAge == 0 {
+		c.MaxAge = base.MaxAge
+	}
+
+	return c
+}
+
+// allowsOrigin returns true if the origin matches any of the allowed ones.
+func (c *Config) allowsOrigin(origin string) bool {
+	for _, allowed := range c.Origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		// e.g. https://*.example.com allows https://app.example.com.
+		if scheme, domain, found := strings.Cut(allowed, "*."); found {
+			rest, hasScheme := strings.CutPrefix(strings.ToLower(origin), strings.ToLower(scheme))
+
+			if hasScheme && strings.HasSuffix(rest, "."+strings.ToLower(domain)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *Config) allowsAnyOrigin() bool {
+	for _, allowed := range c.Origins {
+		if allowed == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Handle sets the CORS headers of the response, if the request comes from an allowed origin. It
+// returns true if the request is a preflight request, which needs no further handling.
+func (c *Config) Handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	header := w.Header()
+
+	// The response depends on the origin, so caches need to tell them apart.
+	header.Add("Vary", "Origin")
+
+	if len(origin) == 0 || !c.allowsOrigin(origin) {
+		return false
+	}
+
+	// Browsers reject the wildcard when credentials are allowed, which Validate rules out anyway.
+	if c.allowsAnyOrigin() {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.Credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if r.Method != http.MethodOptions || len(r.Header.Get("Access-Control-Request-Method")) == 0 {
+		return false
+	}
+
+	header.Set("Access-Control-Allow-Methods", strings.Join(c.Methods, ", "))
+
+	if requested := r.Header.Get("Access-Control-Request-Headers"); len(requested) > 0 {
+		allowed := strings.Join(c.Headers, ", ")
+
+		for _, h := range c.Headers {
+			if h == "*" {
+				allowed = requested
+			}
+		}
+
+		header.Set("Access-Control-Allow-Headers", allowed)
+	}
+
+	if c.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+	}
+
+	return true
+}
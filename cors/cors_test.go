@@ -0,0 +1,196 @@
+package cors
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowsOrigin(t *testing.T) {
+	tests := []struct {
+		origins []string
+		origin  string
+		allowed bool
+	}{
+		{[]string{"https://example.com"}, "https://example.com", true},
+		{[]string{"https://example.com"}, "HTTPS://Example.com", true},
+		{[]string{"https://example.com"}, "http://example.com", false},
+		{[]string{"https://example.com"}, "https://example.com:8443", false},
+		{[]string{"https://example.com"}, "https://app.example.com", false},
+		{[]string{"https://*.example.com"}, "https://app.example.com", true},
+		{[]string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{[]string{"https://*.example.com"}, "https://APP.Example.COM", true},
+		{[]string{"https://*.example.com"}, "https://example.com", false},
+		{[]string{"https://*.example.com"}, "https://evilexample.com", false},
+		{[]string{"https://*.example.com"}, "https://app.example.com.evil.com", false},
+		{[]string{"https://*.example.com"}, "http://app.example.com", false},
+		{[]string{"https://a.com", "https://b.com"}, "https://b.com", true},
+		{[]string{"*"}, "https://anything.test", true},
+		{[]string{"*"}, "null", true},
+		{nil, "https://example.com", false},
+	}
+
+	for _, test := range tests {
+		c := &Config{Origins: test.origins}
+
+		if allowed := c.allowsOrigin(test.origin); allowed != test.allowed {
+			t.Errorf("%v allows %q = %v, expected %v", test.origins, test.origin, allowed, test.allowed)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	config := &Config{
+		Origins: []string{"https://example.com", "https://*.example.org"},
+		Methods: []string{"GET", "POST"},
+		Headers: []string{"X-AUTH-TOKEN", "Content-Type"},
+		MaxAge:  600,
+	}
+	anyOrigin := &Config{Origins: []string{"*"}, Methods: []string{"GET"}, Headers: []string{"*"}}
+	credentials := &Config{Origins: []string{"https://example.com"}, Methods: []string{"GET"}, Credentials: true}
+
+	tests := []struct {
+		name      string
+		config    *Config
+		method    string
+		headers   map[string]string
+		preflight bool
+		// expected are the response headers, where an empty value means the header isn't set.
+		expected map[string]string
+	}{
+		{
+			name:     "no origin",
+			config:   config,
+			method:   "GET",
+			expected: map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			name:     "disallowed origin",
+			config:   config,
+			method:   "GET",
+			headers:  map[string]string{"Origin": "https://evil.com"},
+			expected: map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			name:    "simple request",
+			config:  config,
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://example.com"},
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "",
+				"Access-Control-Allow-Methods":     "",
+			},
+		},
+		{
+			name:      "preflight",
+			config:    config,
+			method:    "OPTIONS",
+			headers:   map[string]string{"Origin": "https://app.example.org", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "x-auth-token"},
+			preflight: true,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.org",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "X-AUTH-TOKEN, Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:     "OPTIONS without a requested method",
+			config:   config,
+			method:   "OPTIONS",
+			headers:  map[string]string{"Origin": "https://example.com"},
+			expected: map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:      "preflight from a disallowed origin",
+			config:    config,
+			method:    "OPTIONS",
+			headers:   map[string]string{"Origin": "https://example.org", "Access-Control-Request-Method": "GET"},
+			preflight: false,
+			expected:  map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:      "any origin with any header",
+			config:    anyOrigin,
+			method:    "OPTIONS",
+			headers:   map[string]string{"Origin": "https://site.test", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "x-custom"},
+			preflight: true,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Headers": "x-custom",
+				"Access-Control-Max-Age":       "",
+			},
+		},
+		{
+			name:    "credentials",
+			config:  credentials,
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://example.com"},
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "/api/databases", nil)
+
+			for name, value := range test.headers {
+				r.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+
+			if preflight := test.config.Handle(w, r); preflight != test.preflight {
+				t.Errorf("Handle() = %v, expected %v", preflight, test.preflight)
+			}
+
+			for name, expected := range test.expected {
+				if got := w.Header().Get(name); got != expected {
+					t.Errorf("%s = %q, expected %q", name, got, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{Origins: []string{"*"}}, true},
+		{Config{Origins: []string{"https://example.com"}, Credentials: true}, true},
+		{Config{Origins: []string{"https://*.example.com"}, Credentials: true}, true},
+		{Config{Origins: []string{"https://example.com", "*"}, Credentials: true}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.config.Validate(); (err == nil) != test.valid {
+			t.Errorf("Validate(%+v) = %v, expected valid = %v", test.config, err, test.valid)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := Config{Origins: []string{"*"}, Methods: []string{"GET"}, Headers: []string{"X-AUTH-TOKEN"}, MaxAge: 600}
+	merged := Config{Origins: []string{"https://example.com"}, Headers: []string{"Content-Type"}}.Merge(base)
+
+	if len(merged.Origins) != 1 || merged.Origins[0] != "https://example.com" {
+		t.Errorf("expected the origins to be kept, got %v", merged.Origins)
+	}
+
+	if len(merged.Methods) != 1 || merged.Methods[0] != "GET" {
+		t.Errorf("expected the methods of the base, got %v", merged.Methods)
+	}
+
+	if len(merged.Headers) != 1 || merged.Headers[0] != "Content-Type" {
+		t.Errorf("expected the headers to be kept, got %v", merged.Headers)
+	}
+
+	if merged.MaxAge != 600 {
+		t.Errorf("expected the max age of the base, got %d", merged.MaxAge)
+	}
+}
@@ -1,9 +1,9 @@
 package crypto
 
 import (
+	"crypto/rand"
 	"encoding/hex"
 	"hash"
-	"math/rand"
 
 	"golang.org/x/crypto/sha3"
 )
@@ -29,10 +29,10 @@ func ByteArrayToHex(payload []byte) string {
 	return hex.EncodeToString(payload)
 }
 
-// GenRandomBytes generates a byte array containing "n" random bytes.
-func GenRandomBytes(n, seed int64) ([]byte, error) {
+// GenRandomBytes generates a byte array containing "n" random bytes from the system's secure
+// random number generator.
+func GenRandomBytes(n int) ([]byte, error) {
 	randBytes := make([]byte, n)
-	rand.Seed(seed)
 	_, err := rand.Read(randBytes)
 
 	return randBytes, err
@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+const (
+	// TokenPrefix starts every token, so that secret scanners can tell them apart from other
+	// random strings.
+	TokenPrefix = "geoip_"
+	// tokenRandomBytes is the number of random bytes in a token.
+	tokenRandomBytes = 32
+	// tokenChecksumLen is the length of the hex encoded CRC32 at the end of a token.
+	tokenChecksumLen = 8
+)
+
+// tokenChecksum returns the checksum of a token's random part.
+func tokenChecksum(random string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(random)))
+}
+
+// GenToken generates a new token. Tokens are made of the prefix, the random part, and a checksum
+// of the random part (e.g. geoip_<64 hex characters><8 hex characters>).
+func GenToken() (string, error) {
+	randBytes, err := GenRandomBytes(tokenRandomBytes)
+
+	if err != nil {
+		return "", err
+	}
+
+	random := hex.EncodeToString(randBytes)
+
+	return TokenPrefix + random + tokenChecksum(random), nil
+}
+
+// IsValidToken returns true if the token has the expected format and its checksum matches. It
+// doesn't say anything about whether the token belongs to a key.
+func IsValidToken(token string) bool {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return false
+	}
+
+	body := strings.TrimPrefix(token, TokenPrefix)
+
+	if len(body) != tokenRandomBytes*2+tokenChecksumLen {
+		return false
+	}
+
+	random, checksum := body[:tokenRandomBytes*2], body[tokenRandomBytes*2:]
+
+	if _, err := hex.DecodeString(random); err != nil {
+		return false
+	}
+
+	return EqualStrings(checksum, tokenChecksum(random))
+}
+
+// EqualStrings compares two strings in constant time, so that the time it takes doesn't reveal
+// how much of a secret was guessed correctly.
+func EqualStrings(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -1,66 +1,217 @@
 package db
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/oschwald/maxminddb-golang"
+	"github.com/wisepythagoras/geoip-service/cache"
 	"github.com/wisepythagoras/geoip-service/dns"
 	"github.com/wisepythagoras/geoip-service/extension"
 	"github.com/wisepythagoras/geoip-service/types"
 )
 
 type DB struct {
-	cityMmdb   *maxminddb.Reader
-	asnMmdb    *maxminddb.Reader
+	// The lock guards the readers: lookups hold it for reading, while a reload holds it for
+	// writing to swap them, which also guarantees no lookup is still using the old readers.
+	lock     sync.RWMutex
+	readers  []*maxminddb.Reader
+	modTimes map[string]time.Time
+	// closed is set by Close, under the lock, so that a reload can't open the databases again.
+	closed bool
+	// generation is increased on every reload, so that cached results of the old databases are
+	// never served again.
+	generation atomic.Uint64
+	// Sources are the databases to open. If none are set, DefaultSources are used.
+	Sources    []*Source
 	Extensions []*extension.Extension
+	// Cache holds the database lookups of GetIPInformation for CacheTTL. It's optional. The lookup
+	// extensions aren't cached, since their data can depend on the client.
+	Cache    *cache.Cache[*types.IPRecord]
+	CacheTTL time.Duration
+	// OnReload is called after the reloads that Watch and ReloadOnSignal trigger, with what
+	// triggered them. It's optional.
+	OnReload func(trigger string, err error)
+}
+
+// NewCache creates a lookup cache that takes up to maxSize bytes. The size of a record is
+// estimated from its JSON encoding.
+func NewCache(maxSize int) *cache.Cache[*types.IPRecord] {
+	return cache.New(maxSize, func(key string, rec *types.IPRecord) int {
+		data, _ := json.Marshal(rec)
+		return len(key) + len(data)
+	})
 }
 
 // Open finds the databases in the filesystem and opens them.
 func (db *DB) Open() error {
-	// Load the city database.
-	cityMmdb, err := maxminddb.Open("geolite/GeoLite2-City.mmdb")
+	if len(db.Sources) == 0 {
+		db.Sources = DefaultSources
+	}
+
+	sortSources(db.Sources)
+	readers, err := openReaders(db.Sources)
 
 	if err != nil {
 		return err
 	}
 
-	db.cityMmdb = cityMmdb
+	db.lock.Lock()
+	defer db.lock.Unlock()
 
-	// Load the ASN database.
-	asnMmdb, err := maxminddb.Open("geolite/GeoLite2-ASN.mmdb")
+	db.readers = readers
+	db.modTimes = db.readModTimes()
 
-	if err != nil {
-		return err
+	return nil
+}
+
+// Lookup looks up an IP address in the databases only, skipping the cache and the extensions.
+func (db *DB) Lookup(ip net.IP) (*types.IPRecord, error) {
+	rec := &types.IPRecord{}
+
+	if err := db.lookup(ip, rec); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// lookup merges the information of an IP address from every database into the record.
+func (db *DB) lookup(ip net.IP, rec *types.IPRecord) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	// The databases are closed once the server shuts down, which a late request can still run into.
+	if len(db.readers) != len(db.Sources) {
+		return errors.New("the databases aren't open")
 	}
 
-	db.asnMmdb = asnMmdb
+	rec.Databases = make(map[string]types.DatabaseMatch)
+
+	for i, source := range db.Sources {
+		var network *net.IPNet
+		var found bool
+		var err error
+
+		if source.Kind == KindCustom {
+			var data map[string]any
+			network, found, err = db.readers[i].LookupNetwork(ip, &data)
+
+			if err != nil {
+				return err
+			}
+
+			if data != nil {
+				if rec.Custom == nil {
+					rec.Custom = make(map[string]any)
+				}
+
+				rec.Custom[source.Name] = data
+			}
+		} else {
+			network, found, err = db.readers[i].LookupNetwork(ip, &rec)
+
+			if err != nil {
+				log.Println(err)
+				return err
+			}
+		}
+
+		// The network is returned even if nothing was found, since the absence of data holds
+		// for the whole network as well.
+		metadata := db.readers[i].Metadata
+		rec.Databases[source.Name] = types.DatabaseMatch{
+			Network:      network.String(),
+			Found:        found,
+			DatabaseType: metadata.DatabaseType,
+			BuildEpoch:   metadata.BuildEpoch,
+		}
+	}
 
 	return nil
 }
 
-func (db *DB) GetIPInformation(hostname string, clientIP *net.IP) (*types.IPRecord, error) {
-	// If you are using strings that may be invalid, check that ip is not nil.
-	ip := net.ParseIP(hostname)
+// Close waits for the in-flight lookups to finish and closes the databases.
+func (db *DB) Close() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
 
-	// Create an instance of the IP record.
-	rec := &types.IPRecord{}
+	closeReaders(db.readers)
+	db.readers = nil
+	db.closed = true
+}
 
-	// Lookup the IP details from the city database.
-	err := db.cityMmdb.Lookup(ip, &rec)
+// Probe checks that every database is open and can answer a lookup.
+func (db *DB) Probe() error {
+	db.lock.RLock()
+	opened := len(db.readers) == len(db.Sources) && len(db.readers) > 0
+	db.lock.RUnlock()
 
-	if err != nil {
-		return nil, err
+	if !opened {
+		return errors.New("the databases aren't open")
 	}
 
-	// Lookup the IP details from the ASN database.
-	err = db.asnMmdb.Lookup(ip, &rec)
+	return db.lookup(net.ParseIP("1.1.1.1"), &types.IPRecord{})
+}
+
+// Databases returns the details and metadata of every loaded database.
+func (db *DB) Databases() []types.DatabaseInfo {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
 
-	if err != nil {
-		log.Println(err)
-		return nil, err
+	databases := []types.DatabaseInfo{}
+
+	if len(db.readers) != len(db.Sources) {
+		return databases
+	}
+
+	for i, source := range db.Sources {
+		metadata := db.readers[i].Metadata
+		databases = append(databases, types.DatabaseInfo{
+			Name:                     source.Name,
+			Kind:                     source.Kind,
+			DatabaseType:             metadata.DatabaseType,
+			Description:              metadata.Description,
+			Languages:                metadata.Languages,
+			BuildEpoch:               metadata.BuildEpoch,
+			BuiltAt:                  time.Unix(int64(metadata.BuildEpoch), 0).UTC(),
+			IPVersion:                metadata.IPVersion,
+			NodeCount:                metadata.NodeCount,
+			RecordSize:               metadata.RecordSize,
+			BinaryFormatMajorVersion: metadata.BinaryFormatMajorVersion,
+			BinaryFormatMinorVersion: metadata.BinaryFormatMinorVersion,
+		})
+	}
+
+	return databases
+}
+
+func (db *DB) GetIPInformation(hostname string, clientIP *net.IP) (*types.IPRecord, error) {
+	// If you are using strings that may be invalid, check that ip is not nil.
+	ip := net.ParseIP(hostname)
+	cacheKey := fmt.Sprintf("%d %s", db.generation.Load(), ip)
+	rec, ok := db.Cache.Get(cacheKey)
+
+	if ok && ip != nil {
+		rec = rec.Clone()
+	} else {
+		// Create an instance of the IP record.
+		rec = &types.IPRecord{}
+
+		if err := db.lookup(ip, rec); err != nil {
+			return nil, err
+		}
+
+		if ip != nil {
+			db.Cache.Set(cacheKey, rec.Clone(), db.CacheTTL)
+		}
 	}
 
 	var addlData []any
@@ -112,6 +263,8 @@ func (db *DB) GetDomainInformation(hostname string, dnsServerList []string, clie
 			continue
 		}
 
+		info.RecordType = dns.RecordTypeForIP(ips[i].IP)
+
 		// Append the record to the array.
 		records = append(records, info)
 	}
@@ -145,9 +298,60 @@ func (db *DB) GetDomainInfoFromDNS(
 			continue
 		}
 
+		info.RecordType = dns.RecordTypeForIP(ips[i])
+
 		// Append the record to the array.
 		records = append(records, info)
 	}
 
 	return records, nil
 }
+
+// GetDomainRecords queries the requested record types of a domain and geolocates every IP found
+// in them. Records that point to a host (CNAME, MX, NS) have that host resolved first.
+func (db *DB) GetDomainRecords(
+	hostname string,
+	recordTypes []uint16,
+	dnsServerList []string,
+	clientIP *net.IP,
+) ([]*types.DNSRecord, error) {
+	// Is this a valid domain name?
+	if !govalidator.IsDNSName(hostname) {
+		// Make sure the request is valid.
+		return []*types.DNSRecord{}, errors.New("invalid input")
+	}
+
+	records, err := dns.DNSRecordLookup(hostname, recordTypes, dnsServerList)
+
+	if err != nil {
+		return []*types.DNSRecord{}, err
+	}
+
+	// Several records may point to the same host, so only resolve each one once.
+	hostIPs := make(map[string][]net.IP)
+
+	for _, record := range records {
+		ips := dns.RecordIPs(record)
+
+		if len(record.Target) > 0 {
+			if _, ok := hostIPs[record.Target]; !ok {
+				hostIPs[record.Target], _ = dns.DNSAnyLookup(record.Target, dnsServerList)
+			}
+
+			ips = hostIPs[record.Target]
+		}
+
+		for _, ip := range ips {
+			info, err := db.GetIPInformation(ip.String(), clientIP)
+
+			if err != nil {
+				continue
+			}
+
+			info.RecordType = dns.RecordTypeForIP(ip)
+			record.Addresses = append(record.Addresses, info)
+		}
+	}
+
+	return records, nil
+}
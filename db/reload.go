@@ -0,0 +1,125 @@
+package db
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// readModTimes returns the modification time of each database file that exists.
+func (db *DB) readModTimes() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+
+	for _, source := range db.Sources {
+		path := source.Path
+
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+// Reload opens the databases again and swaps them in. Lookups keep using the old readers until
+// the new ones are ready, and the old readers are only closed once every in-flight lookup has
+// finished with them. If the new files can't be opened, the current readers are kept. Once the
+// databases are closed, they can't be reloaded.
+func (db *DB) Reload() error {
+	modTimes := db.readModTimes()
+	readers, err := openReaders(db.Sources)
+
+	if err != nil {
+		return err
+	}
+
+	// Taking the write lock waits for all the lookups holding the read lock to finish.
+	db.lock.Lock()
+
+	if db.closed {
+		db.lock.Unlock()
+		closeReaders(readers)
+
+		return errors.New("the databases are closed")
+	}
+
+	oldReaders := db.readers
+	db.readers = readers
+	db.modTimes = modTimes
+	db.lock.Unlock()
+
+	closeReaders(oldReaders)
+
+	// The cached results may be out of date now.
+	db.generation.Add(1)
+	db.Cache.Purge()
+
+	log.Println("Reloaded the databases")
+
+	return nil
+}
+
+// hasChanged returns true if any of the database files were modified since they were opened.
+func (db *DB) hasChanged() bool {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	for path, modTime := range db.readModTimes() {
+		if !modTime.Equal(db.modTimes[path]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reloaded reports a reload to OnReload, if it's set.
+func (db *DB) reloaded(trigger string, err error) {
+	if db.OnReload != nil {
+		db.OnReload(trigger, err)
+	}
+}
+
+// Watch polls the database files every interval and reloads them when they change. A failed
+// reload (e.g. a file that is still being written) is retried on the next tick.
+func (db *DB) Watch(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if !db.hasChanged() {
+				continue
+			}
+
+			err := db.Reload()
+
+			if err != nil {
+				log.Println("Unable to reload the databases:", err)
+			}
+
+			db.reloaded("file change", err)
+		}
+	}()
+}
+
+// ReloadOnSignal reloads the databases whenever the process receives a SIGHUP.
+func (db *DB) ReloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			err := db.Reload()
+
+			if err != nil {
+				log.Println("Unable to reload the databases:", err)
+			}
+
+			db.reloaded("SIGHUP", err)
+		}
+	}()
+}
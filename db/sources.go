@@ -0,0 +1,118 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// The kinds of databases that can be configured. Every kind except custom is decoded into the
+// matching fields of the IP record, while custom databases are returned as they are.
+const (
+	KindCountry        = "country"
+	KindCity           = "city"
+	KindASN            = "asn"
+	KindISP            = "isp"
+	KindAnonymousIP    = "anonymous-ip"
+	KindConnectionType = "connection-type"
+	KindDomain         = "domain"
+	KindCustom         = "custom"
+)
+
+// kindOrder is the order in which the databases are looked up. Country comes before City, so
+// that the City information takes precedence and Country only fills in when City is missing.
+var kindOrder = []string{
+	KindCountry,
+	KindCity,
+	KindASN,
+	KindISP,
+	KindAnonymousIP,
+	KindConnectionType,
+	KindDomain,
+	KindCustom,
+}
+
+// Source is a single mmdb file that lookups are merged from.
+type Source struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// DefaultSources are the databases used when none are configured.
+var DefaultSources = []*Source{
+	{Kind: KindCity, Name: KindCity, Path: "geolite/GeoLite2-City.mmdb"},
+	{Kind: KindASN, Name: KindASN, Path: "geolite/GeoLite2-ASN.mmdb"},
+}
+
+func kindIndex(kind string) int {
+	for i, k := range kindOrder {
+		if k == kind {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ParseSource parses a database definition in the form kind=path or kind:name=path. The name is
+// only needed to tell custom databases apart and defaults to the file's name.
+func ParseSource(def string) (*Source, error) {
+	kindName, path, found := strings.Cut(def, "=")
+
+	if !found || len(path) == 0 {
+		return nil, fmt.Errorf("invalid database %q (expected kind=path)", def)
+	}
+
+	kind, name, _ := strings.Cut(strings.ToLower(kindName), ":")
+
+	if kindIndex(kind) < 0 {
+		return nil, fmt.Errorf("unknown database kind %q (expected one of %s)", kind, strings.Join(kindOrder, ", "))
+	}
+
+	if len(name) == 0 {
+		name = kind
+
+		if kind == KindCustom {
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+	}
+
+	return &Source{Kind: kind, Name: name, Path: path}, nil
+}
+
+// sortSources orders the sources by kind, in the order they should be merged.
+func sortSources(sources []*Source) {
+	sort.SliceStable(sources, func(i, j int) bool {
+		return kindIndex(sources[i].Kind) < kindIndex(sources[j].Kind)
+	})
+}
+
+// openReaders opens every source's database. Either all of them are opened or none are.
+func openReaders(sources []*Source) ([]*maxminddb.Reader, error) {
+	readers := []*maxminddb.Reader{}
+
+	for _, source := range sources {
+		reader, err := maxminddb.Open(source.Path)
+
+		if err != nil {
+			closeReaders(readers)
+			return nil, fmt.Errorf("unable to open the %s database: %w", source.Name, err)
+		}
+
+		readers = append(readers, reader)
+	}
+
+	return readers, nil
+}
+
+func closeReaders(readers []*maxminddb.Reader) {
+	for _, reader := range readers {
+		if reader != nil {
+			reader.Close()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/wisepythagoras/geoip-service/cache"
+)
+
+// CachedMsg is a DNS server's response along with the time it was received.
+type CachedMsg struct {
+	Msg        *dns.Msg
+	ReceivedAt time.Time
+}
+
+// Cache holds the responses of the DNS servers for as long as their records' TTLs allow. It's
+// disabled (nil) unless set up with NewCache.
+var Cache *cache.Cache[*CachedMsg]
+
+// NewCache creates a response cache that takes up to maxSize bytes. The size of a response is
+// estimated from its wire size, since the parsed records take up a few times more.
+func NewCache(maxSize int) *cache.Cache[*CachedMsg] {
+	return cache.New(maxSize, func(key string, cached *CachedMsg) int {
+		return len(key) + cached.Msg.Len()*4
+	})
+}
+
+// minTTL returns the lowest TTL of the records in the answer, which is how long the answer can be
+// cached. Answers without records aren't cached.
+func minTTL(msg *dns.Msg) time.Duration {
+	if len(msg.Answer) == 0 {
+		return 0
+	}
+
+	ttl := msg.Answer[0].Header().Ttl
+
+	for _, rr := range msg.Answer[1:] {
+		ttl = min(ttl, rr.Header().Ttl)
+	}
+
+	return time.Duration(ttl) * time.Second
+}
+
+// exchange sends a query to a DNS server, or answers it from the cache. Cached answers have their
+// TTLs lowered by the time they spent in the cache, like a resolver would.
+func exchange(client *dns.Client, msg *dns.Msg, dnsServer string) (*dns.Msg, error) {
+	question := msg.Question[0]
+	key := fmt.Sprintf("%s %s %d", dnsServer, question.Name, question.Qtype)
+
+	if cached, ok := Cache.Get(key); ok {
+		r := cached.Msg.Copy()
+		elapsed := uint32(time.Since(cached.ReceivedAt).Seconds())
+
+		for _, rr := range r.Answer {
+			rr.Header().Ttl -= min(rr.Header().Ttl, elapsed)
+		}
+
+		return r, nil
+	}
+
+	start := time.Now()
+	r, _, err := client.Exchange(msg, dnsServer)
+	queryDuration.With(dnsServer).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		queryFailures.With(dnsServer).Inc()
+		return nil, err
+	}
+
+	Cache.Set(key, &CachedMsg{Msg: r, ReceivedAt: time.Now()}, minTTL(r))
+
+	return r, nil
+}
@@ -2,9 +2,12 @@ package dns
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 
 	"github.com/miekg/dns"
+	"github.com/wisepythagoras/geoip-service/types"
 )
 
 var DefaultDNSServers = []string{
@@ -47,26 +50,19 @@ func CreateDialer(dnsServer string) func(context.Context, string, string) (net.C
 	}
 }
 
-// DNSLookup queries the specified DNS servers (or the default ones).
+// DNSLookup queries the specified DNS servers (or the default ones) for both the A and AAAA
+// records of a domain. The answers are cached like those of the other lookups.
 func DNSLookup(domain string, dnsServers []string) ([]net.IPAddr, error) {
-	ipAddresses := []net.IPAddr{}
+	ips, err := DNSAnyLookup(domain, dnsServers)
 
-	if len(dnsServers) == 0 {
-		dnsServers = DefaultDNSServers
+	if err != nil {
+		return nil, err
 	}
 
-	for _, dnsServer := range dnsServers {
-		r := net.Resolver{
-			Dial: CreateDialer(dnsServer),
-		}
-		ctx := context.Background()
-		ips, err := r.LookupIPAddr(ctx, domain)
-
-		if err != nil {
-			return nil, err
-		}
+	ipAddresses := make([]net.IPAddr, 0, len(ips))
 
-		ipAddresses = MergeIPArrays(ipAddresses, ips)
+	for _, ip := range ips {
+		ipAddresses = append(ipAddresses, net.IPAddr{IP: ip})
 	}
 
 	return ipAddresses, nil
@@ -89,7 +85,7 @@ func DNSALookup(domain string, dnsServers []string) ([]net.IP, error) {
 		msg.SetQuestion(dns.Fqdn(domain), dns.TypeA)
 		msg.RecursionDesired = true
 
-		r, _, err := client.Exchange(msg, dnsServer)
+		r, err := exchange(client, msg, dnsServer)
 
 		if err != nil {
 			return nil, err
@@ -124,7 +120,7 @@ func DNSAAAALookup(domain string, dnsServers []string) ([]net.IP, error) {
 		msgA4.SetQuestion(dns.Fqdn(domain), dns.TypeAAAA)
 		msgA4.RecursionDesired = true
 
-		rA4, _, err := client.Exchange(msgA4, dnsServer)
+		rA4, err := exchange(client, msgA4, dnsServer)
 
 		if err != nil {
 			return nil, err
@@ -143,3 +139,140 @@ func DNSAAAALookup(domain string, dnsServers []string) ([]net.IP, error) {
 
 	return ipAddresses, nil
 }
+
+// DNSAnyLookup queries both the A and AAAA records of a domain, for dual-stack hosts. It only fails
+// if both queries fail, so that a host is still found if one of its families can't be resolved.
+func DNSAnyLookup(domain string, dnsServers []string) ([]net.IP, error) {
+	ipv4, errA := DNSALookup(domain, dnsServers)
+	ipv6, errAAAA := DNSAAAALookup(domain, dnsServers)
+
+	if errA != nil && errAAAA != nil {
+		return nil, errors.Join(errA, errAAAA)
+	}
+
+	return append(ipv4, ipv6...), nil
+}
+
+// CallerForFamily returns the lookup function for an address family, which can be "4" (A records),
+// "6" (AAAA records), or "any" (both).
+func CallerForFamily(family string) (DNSCaller, error) {
+	switch family {
+	case "4":
+		return DNSALookup, nil
+	case "6":
+		return DNSAAAALookup, nil
+	case "any":
+		return DNSAnyLookup, nil
+	}
+
+	return nil, fmt.Errorf("invalid address family %q (expected 4, 6, or any)", family)
+}
+
+// RecordTypeForIP returns the type of the DNS record an IP address would be found in.
+func RecordTypeForIP(ip net.IP) string {
+	if ip.To4() != nil {
+		return "A"
+	}
+
+	return "AAAA"
+}
+
+// DNSPTRLookup queries the PTR records of an IP address.
+func DNSPTRLookup(ip net.IP, dnsServers []string) ([]string, error) {
+	client := new(dns.Client)
+
+	if len(dnsServers) == 0 {
+		dnsServers = DefaultDNSServers
+	}
+
+	arpa, err := dns.ReverseAddr(ip.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	hostnameMap := make(map[string]bool)
+	hostnames := []string{}
+
+	for _, dnsServer := range dnsServers {
+		msg := new(dns.Msg)
+		msg.SetQuestion(arpa, dns.TypePTR)
+		msg.RecursionDesired = true
+
+		r, err := exchange(client, msg, dnsServer)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, answer := range r.Answer {
+			if ptr, ok := answer.(*dns.PTR); ok && !hostnameMap[ptr.Ptr] {
+				hostnameMap[ptr.Ptr] = true
+				hostnames = append(hostnames, ptr.Ptr)
+			}
+		}
+	}
+
+	return hostnames, nil
+}
+
+// ReverseLookup finds the host names of an IP address. If confirm is set, each host name is
+// resolved again to check whether it points back to the same address (FCrDNS).
+func ReverseLookup(ip net.IP, dnsServers []string, confirm bool) ([]types.PTRRecord, error) {
+	hostnames, err := DNSPTRLookup(ip, dnsServers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	caller := DNSALookup
+
+	if ip.To4() == nil {
+		caller = DNSAAAALookup
+	}
+
+	records := []types.PTRRecord{}
+
+	for _, hostname := range hostnames {
+		record := types.PTRRecord{Hostname: hostname}
+
+		if confirm {
+			confirmed := false
+			ips, _ := caller(hostname, dnsServers)
+
+			for _, addr := range ips {
+				if addr.Equal(ip) {
+					confirmed = true
+					break
+				}
+			}
+
+			record.ForwardConfirmed = &confirmed
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// Ping checks that a DNS server answers queries, by asking it for the root name servers.
+func Ping(dnsServer string) error {
+	client := new(dns.Client)
+	msg := new(dns.Msg)
+	msg.SetQuestion(".", dns.TypeNS)
+	msg.RecursionDesired = true
+
+	r, _, err := client.Exchange(msg, dnsServer)
+
+	if err != nil {
+		return err
+	}
+
+	// Any other answer means the server is up, even if it's unusual (e.g. NXDOMAIN).
+	if r.Rcode == dns.RcodeServerFailure || r.Rcode == dns.RcodeRefused {
+		return fmt.Errorf("the server answered with %s", dns.RcodeToString[r.Rcode])
+	}
+
+	return nil
+}
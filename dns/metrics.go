@@ -0,0 +1,17 @@
+package dns
+
+import "github.com/wisepythagoras/geoip-service/metrics"
+
+var (
+	queryDuration = metrics.NewHistogramVec(
+		"geoip_dns_query_duration_seconds",
+		"The time it took the upstream DNS servers to answer a query.",
+		metrics.DefaultBuckets,
+		"server",
+	)
+	queryFailures = metrics.NewCounterVec(
+		"geoip_dns_query_failures_total",
+		"The number of queries to the upstream DNS servers that failed.",
+		"server",
+	)
+)
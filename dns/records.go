@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+// SupportedRecordTypes lists the record types the record explorer can query.
+var SupportedRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "TXT", "SOA", "CAA"}
+
+// ParseRecordTypes parses a comma separated list of record types (e.g. "MX,NS,TXT"). An empty
+// list means every supported type.
+func ParseRecordTypes(list string) ([]uint16, error) {
+	names := SupportedRecordTypes
+
+	if len(strings.TrimSpace(list)) > 0 {
+		names = strings.Split(list, ",")
+	}
+
+	recordTypes := []uint16{}
+	seen := make(map[uint16]bool)
+
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		supported := false
+
+		for _, t := range SupportedRecordTypes {
+			if t == name {
+				supported = true
+				break
+			}
+		}
+
+		if !supported {
+			return nil, fmt.Errorf("unsupported record type %q", name)
+		}
+
+		t := dns.StringToType[name]
+
+		if !seen[t] {
+			seen[t] = true
+			recordTypes = append(recordTypes, t)
+		}
+	}
+
+	return recordTypes, nil
+}
+
+// recordFromRR converts a resource record into its structured representation.
+func recordFromRR(rr dns.RR) *types.DNSRecord {
+	hdr := rr.Header()
+	record := &types.DNSRecord{
+		Name:  hdr.Name,
+		Type:  dns.TypeToString[hdr.Rrtype],
+		TTL:   hdr.Ttl,
+		Value: strings.TrimPrefix(rr.String(), hdr.String()),
+	}
+
+	switch r := rr.(type) {
+	case *dns.A:
+		record.Value = r.A.String()
+	case *dns.AAAA:
+		record.Value = r.AAAA.String()
+	case *dns.CNAME:
+		record.Target = r.Target
+	case *dns.NS:
+		record.Target = r.Ns
+	case *dns.MX:
+		record.Target = r.Mx
+		record.MX = &types.MXRecord{
+			Preference: r.Preference,
+			Exchange:   r.Mx,
+		}
+	case *dns.TXT:
+		record.Text = r.Txt
+	case *dns.SOA:
+		record.SOA = &types.SOARecord{
+			NS:      r.Ns,
+			Mbox:    r.Mbox,
+			Serial:  r.Serial,
+			Refresh: r.Refresh,
+			Retry:   r.Retry,
+			Expire:  r.Expire,
+			MinTTL:  r.Minttl,
+		}
+	case *dns.CAA:
+		record.CAA = &types.CAARecord{
+			Flag:  r.Flag,
+			Tag:   r.Tag,
+			Value: r.Value,
+		}
+	}
+
+	return record
+}
+
+// DNSRecordLookup queries every DNS server for the given record types and returns the distinct
+// records found. Records of other types in the answer (e.g. the CNAME chain of an A query) are
+// only kept if they were also asked for.
+func DNSRecordLookup(domain string, recordTypes []uint16, dnsServers []string) ([]*types.DNSRecord, error) {
+	client := new(dns.Client)
+
+	if len(dnsServers) == 0 {
+		dnsServers = DefaultDNSServers
+	}
+
+	wanted := make(map[uint16]bool)
+
+	for _, t := range recordTypes {
+		wanted[t] = true
+	}
+
+	seen := make(map[string]bool)
+	records := []*types.DNSRecord{}
+
+	for _, recordType := range recordTypes {
+		for _, dnsServer := range dnsServers {
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn(domain), recordType)
+			msg.RecursionDesired = true
+
+			r, err := exchange(client, msg, dnsServer)
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, answer := range r.Answer {
+				if !wanted[answer.Header().Rrtype] {
+					continue
+				}
+
+				record := recordFromRR(answer)
+				key := fmt.Sprintf("%s %s %s", record.Name, record.Type, record.Value)
+
+				if seen[key] {
+					continue
+				}
+
+				seen[key] = true
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// RecordIPs returns the IP address held by an A or AAAA record, or nil for any other type.
+func RecordIPs(record *types.DNSRecord) []net.IP {
+	if record.Type != "A" && record.Type != "AAAA" {
+		return nil
+	}
+
+	if ip := net.ParseIP(record.Value); ip != nil {
+		return []net.IP{ip}
+	}
+
+	return nil
+}
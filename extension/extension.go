@@ -7,12 +7,15 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	js "github.com/dop251/goja"
 	"github.com/gin-gonic/gin"
 	"github.com/go-co-op/gocron"
+	"github.com/wisepythagoras/geoip-service/cors"
 	"github.com/wisepythagoras/geoip-service/jsapi"
+	"github.com/wisepythagoras/geoip-service/openapi"
 )
 
 type EndpointReq struct {
@@ -29,10 +32,16 @@ type EndpointRes struct {
 	HTML  func(status int, html string)       `json:"html"`
 }
 
+// EndpointDetails describes an endpoint of the extension. The summary, params, and response are
+// optional and only used to describe the endpoint in the OpenAPI document.
 type EndpointDetails struct {
-	Endpoint string `json:"endpoint"`
-	Method   string `json:"method"`
-	Handler  string `json:"handler"`
+	Endpoint string               `json:"endpoint"`
+	Method   string               `json:"method"`
+	Handler  string               `json:"handler"`
+	Summary  string               `json:"summary"`
+	Params   []*openapi.Parameter `json:"params"`
+	// Response is the JSON schema of the endpoint's successful response.
+	Response *openapi.Schema `json:"response"`
 }
 
 type CronJob struct {
@@ -46,6 +55,8 @@ type ExtensionConfig struct {
 	Endpoints []EndpointDetails `json:"endpoints"`
 	Jobs      []CronJob         `json:"jobs"`
 	Name      string            `json:"name"`
+	// CORS overrides the server's CORS policy for the extension's endpoints.
+	CORS *cors.Config `json:"cors"`
 }
 
 type InstallFn func() ExtensionConfig
@@ -61,6 +72,9 @@ type Extension struct {
 	scheduler *gocron.Scheduler
 	name      string
 	lookupFn  func(addr string, clientIP string) interface{}
+	ready     atomic.Bool
+	sqlDb     *jsapi.SqlDB
+	cors      *cors.Config
 }
 
 // Init will spin up the JS VM and run the script.
@@ -100,11 +114,11 @@ func (e *Extension) Init() error {
 	}
 	storageObj.Init()
 
-	sqlDb := jsapi.SqlDB{
+	e.sqlDb = &jsapi.SqlDB{
 		VM:      e.vm,
 		DataDir: dataDir,
 	}
-	sqlDb.Init()
+	e.sqlDb.Init()
 
 	_, err = e.vm.RunScript(e.Dir.Name(), string(bytes))
 
@@ -125,6 +139,7 @@ func (e *Extension) Init() error {
 	e.endpoints = res.Endpoints
 	e.hasLookup = res.HasLookup
 	e.name = res.Name
+	e.cors = res.CORS
 
 	if len(res.Name) == 0 || strings.Contains(e.name, " ") {
 		return fmt.Errorf("extension at %q doesn't have a name or the name is malformed", e.Dir.Name())
@@ -149,14 +164,61 @@ func (e *Extension) Init() error {
 		}
 
 		fmt.Println("Registering", job.Cron, job.Job)
-		e.scheduler.Cron(job.Cron).Do(jobHandler)
+		e.scheduler.Cron(job.Cron).Do(e.wrapJob(job.Job, jobHandler))
 	}
 
 	e.scheduler.StartAsync()
+	e.ready.Store(true)
 
 	return nil
 }
 
+// Name returns the name the extension registered itself with.
+func (e *Extension) Name() string {
+	if len(e.name) == 0 {
+		return e.Dir.Name()
+	}
+
+	return e.name
+}
+
+// Close stops the extension's cron jobs, waiting for any running ones to finish, and closes the
+// databases it opened.
+func (e *Extension) Close() error {
+	e.ready.Store(false)
+
+	if e.scheduler != nil {
+		e.scheduler.Stop()
+	}
+
+	if e.sqlDb != nil {
+		return e.sqlDb.Close()
+	}
+
+	return nil
+}
+
+// CORS returns the CORS policy the extension set for its endpoints, or nil if it uses the
+// server's.
+func (e *Extension) CORS() *cors.Config {
+	return e.cors
+}
+
+// Endpoints returns the endpoints the extension defines.
+func (e *Extension) Endpoints() []EndpointDetails {
+	return e.endpoints
+}
+
+// Route returns the route that an endpoint of the extension is registered under.
+func (e *Extension) Route(details EndpointDetails) string {
+	return filepath.Join("/api", e.name, details.Endpoint)
+}
+
+// IsReady returns true once the extension has been initialized successfully.
+func (e *Extension) IsReady() bool {
+	return e.ready.Load()
+}
+
 // IsEndpointExtension returns true if this extension defines an endpoint.
 func (e *Extension) IsEndpointExtension() bool {
 	return len(e.endpoints) > 0
@@ -174,9 +236,48 @@ func (e *Extension) RunIPLookup(ip string, clientIP string) (any, error) {
 		return nil, fmt.Errorf("this extension doesn't have lookup capabilities")
 	}
 
+	start := time.Now()
+	data, err := e.runLookupFn(ip, clientIP)
+	lookupDuration.With(e.name).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		lookupErrors.With(e.name).Inc()
+	}
+
+	return data, err
+}
+
+// runLookupFn calls the extension's lookup function. An exception thrown in the JS code panics,
+// so it's recovered here and returned as an error instead.
+func (e *Extension) runLookupFn(ip string, clientIP string) (data any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("lookup failed in extension %q: %v", e.name, r)
+		}
+	}()
+
 	return e.lookupFn(ip, clientIP), nil
 }
 
+// wrapJob wraps a cron job's handler so that its runs are counted and an exception thrown in it
+// doesn't bring down the scheduler.
+func (e *Extension) wrapJob(name string, handler func()) func() {
+	return func() {
+		result := "success"
+
+		defer func() {
+			if r := recover(); r != nil {
+				result = "error"
+				fmt.Printf("Job %q of extension %q failed: %v\n", name, e.name, r)
+			}
+
+			jobRuns.With(e.name, name, result).Inc()
+		}()
+
+		handler()
+	}
+}
+
 // RegisterEndpoints will go through all of the endpoints and register them with gin.
 func (e *Extension) RegisterEndpoints(r *gin.Engine) bool {
 	if !e.IsEndpointExtension() {
@@ -201,7 +302,7 @@ func (e *Extension) registerEndpoint(r *gin.Engine, details EndpointDetails) boo
 		return false
 	}
 
-	endpoint := filepath.Join("/api", e.name, details.Endpoint)
+	endpoint := e.Route(details)
 
 	endpointHandler := func(c *gin.Context) {
 		// We need a wait group because the JS VM may run an async handler and if we
@@ -0,0 +1,22 @@
+package extension
+
+import "github.com/wisepythagoras/geoip-service/metrics"
+
+var (
+	lookupDuration = metrics.NewHistogramVec(
+		"geoip_extension_lookup_duration_seconds",
+		"The time it took an extension to run its IP lookup.",
+		metrics.DefaultBuckets,
+		"extension",
+	)
+	lookupErrors = metrics.NewCounterVec(
+		"geoip_extension_lookup_errors_total",
+		"The number of IP lookups that failed in an extension.",
+		"extension",
+	)
+	jobRuns = metrics.NewCounterVec(
+		"geoip_extension_job_runs_total",
+		"The number of times an extension's cron job ran, by result (success or error).",
+		"extension", "job", "result",
+	)
+)
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/geofence"
+	"github.com/wisepythagoras/geoip-service/metrics"
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+// clientRecordContextKey is where the lookup of the client's IP is kept in the gin context, once
+// it was needed.
+const clientRecordContextKey = "clientRecord"
+
+var geoRules []*geofence.Rule
+
+var geoDecisions = metrics.NewCounterVec(
+	"geoip_geo_decisions_total",
+	"The decisions of the geo-fencing rules, by the rule that turned the client away (empty if none did) and the decision.",
+	"rule", "decision",
+)
+
+// parseGeoRules parses the geo-fencing rules and checks their route groups.
+func parseGeoRules(defs []string) ([]*geofence.Rule, error) {
+	rules := []*geofence.Rule{}
+
+	for _, def := range defs {
+		rule, err := geofence.ParseRule(def)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rule.Group) > 0 && !isRouteGroup(rule.Group) {
+			return nil, fmt.Errorf("unknown route group %q in the geo rule %q", rule.Group, def)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// checkGeoRules applies the geo-fencing rules of the route to the client, based on the lookup of
+// its IP address. It aborts the request and returns false if the client is turned away. The
+// service routes are exempt (see isServiceRoute).
+func checkGeoRules(c *gin.Context) bool {
+	if isServiceRoute(c.FullPath()) {
+		return true
+	}
+
+	group, name := routeGroup(c.FullPath())
+	rules := []*geofence.Rule{}
+
+	for _, rule := range geoRules {
+		if len(rule.Group) == 0 || rule.Group == group || rule.Group == name {
+			rules = append(rules, rule)
+		}
+	}
+
+	if len(rules) == 0 {
+		return true
+	}
+
+	// Only the databases are needed, so the cache and the lookup extensions are skipped. A client
+	// without an IP address is treated like one that isn't in the databases.
+	clientIP := requestClientIP(c)
+	rec := &types.IPRecord{}
+
+	if clientIP != nil {
+		var err error
+
+		// Without the lookup there is no telling whether the client would be turned away.
+		if rec, err = database.Lookup(clientIP); err != nil {
+			log.Println("Unable to look up the client for the geo rules:", err)
+			c.AbortWithStatus(500)
+			return false
+		}
+	}
+
+	c.Set(clientRecordContextKey, rec)
+
+	_, hasKey := c.Get(apiKeyContextKey)
+	decision := geofence.Evaluate(rules, rec, hasKey || hasPrivilegedClientCert(c))
+
+	if decision.Allowed {
+		geoDecisions.With("", "allowed").Inc()
+		return true
+	}
+
+	status := 403
+	response := &types.ApiResponse{
+		Success: false,
+		Status:  fmt.Sprintf("Access denied by the geo rule %q", decision.Rule.Text),
+	}
+
+	if decision.KeyRequired {
+		status = 401
+		response.Status = fmt.Sprintf("An API key is required by the geo rule %q", decision.Rule.Text)
+		geoDecisions.With(decision.Rule.Text, "key_required").Inc()
+	} else {
+		geoDecisions.With(decision.Rule.Text, "denied").Inc()
+	}
+
+	log.Printf("Geo rule %q turned away %s (country %q, ASN %d) from %s\n", decision.Rule.Text, clientIP, rec.Country.ISOCode, rec.ASN, c.Request.URL.Path)
+	c.AbortWithStatusJSON(status, response)
+
+	return false
+}
@@ -0,0 +1,140 @@
+package geofence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+// The actions a rule can take when its condition matches.
+const (
+	// Deny turns away the clients that match.
+	Deny = "deny"
+	// Allow turns away the clients that don't match.
+	Allow = "allow"
+	// RequireKey makes the clients that match use an API key.
+	RequireKey = "require-key"
+)
+
+// The fields of the client's record that rules can check.
+const (
+	FieldCountry   = "country"
+	FieldContinent = "continent"
+	FieldASN       = "asn"
+)
+
+// Rule is a geo-fencing rule, written as [group=]action:field=values or
+// [group=]action:field!=values, where the values are separated by commas. For example,
+// deny:country=CN,RU, admin=allow:asn=13335, ext:whois=deny:continent=AN, or
+// require-key:country!=US.
+type Rule struct {
+	// Text is the rule as it was written.
+	Text   string
+	Group  string
+	Action string
+	Field  string
+	Negate bool
+	Values map[string]bool
+}
+
+// ParseRule parses a rule.
+func ParseRule(def string) (*Rule, error) {
+	// The group can have a colon in it (e.g. ext:whois), but the condition can't.
+	i := strings.LastIndex(def, ":")
+
+	if i < 0 {
+		return nil, fmt.Errorf("invalid geo rule %q (expected [group=]action:condition)", def)
+	}
+
+	head, condition := def[:i], def[i+1:]
+
+	rule := &Rule{Text: def, Values: make(map[string]bool)}
+
+	if group, action, found := strings.Cut(head, "="); found {
+		rule.Group, rule.Action = group, action
+	} else {
+		rule.Action = head
+	}
+
+	if rule.Action != Deny && rule.Action != Allow && rule.Action != RequireKey {
+		return nil, fmt.Errorf("invalid action %q in the geo rule %q (expected deny, allow, or require-key)", rule.Action, def)
+	}
+
+	field, values, found := strings.Cut(condition, "=")
+
+	if !found || len(values) == 0 {
+		return nil, fmt.Errorf("invalid condition in the geo rule %q (expected field=values or field!=values)", def)
+	}
+
+	if field, rule.Negate = strings.CutSuffix(field, "!"); field != FieldCountry && field != FieldContinent && field != FieldASN {
+		return nil, fmt.Errorf("invalid field %q in the geo rule %q (expected country, continent, or asn)", field, def)
+	}
+
+	rule.Field = field
+
+	for _, value := range strings.Split(values, ",") {
+		value = strings.ToUpper(strings.TrimSpace(value))
+
+		// An empty value would match the addresses that aren't in the databases.
+		if len(value) == 0 {
+			return nil, fmt.Errorf("empty value in the geo rule %q", def)
+		}
+
+		if field == FieldASN {
+			value = strings.TrimPrefix(value, "AS")
+
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid ASN %q in the geo rule %q", value, def)
+			}
+		}
+
+		rule.Values[value] = true
+	}
+
+	return rule, nil
+}
+
+// value returns the field of the record that the rule checks. Addresses that aren't in the
+// databases have an empty country and continent, and an ASN of 0.
+func (r *Rule) value(rec *types.IPRecord) string {
+	switch r.Field {
+	case FieldCountry:
+		return rec.Country.ISOCode
+	case FieldContinent:
+		return rec.Continent.Code
+	}
+
+	return strconv.Itoa(rec.ASN)
+}
+
+// Matches returns true if the record meets the rule's condition.
+func (r *Rule) Matches(rec *types.IPRecord) bool {
+	return r.Values[strings.ToUpper(r.value(rec))] != r.Negate
+}
+
+// Decision is the outcome of the rules for a client.
+type Decision struct {
+	Allowed     bool
+	KeyRequired bool
+	// Rule is the rule that turned the client away, if any.
+	Rule *Rule
+}
+
+// Evaluate goes through the rules in order and returns the decision of the first one that turns
+// the client away. A client with an API key passes the require-key rules.
+func Evaluate(rules []*Rule, rec *types.IPRecord, hasKey bool) Decision {
+	for _, rule := range rules {
+		matches := rule.Matches(rec)
+
+		switch {
+		case rule.Action == Deny && matches, rule.Action == Allow && !matches:
+			return Decision{Rule: rule}
+		case rule.Action == RequireKey && matches && !hasKey:
+			return Decision{KeyRequired: true, Rule: rule}
+		}
+	}
+
+	return Decision{Allowed: true}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/dns"
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+var readyChecksDNS = false
+
+// HealthHandler only tells whether the process is alive and serving requests.
+func HealthHandler(c *gin.Context) {
+	c.JSON(200, &types.ApiResponse{
+		Success: true,
+		Status:  "Alive",
+	})
+}
+
+// ReadyHandler checks whether every component is able to serve lookups: the databases need to be
+// open and answer a probe lookup, and every extension needs to be initialized. If -ready-dns was
+// set, at least one DNS server also needs to answer.
+func ReadyHandler(c *gin.Context) {
+	components := make(map[string]*types.ComponentStatus)
+	ready := true
+
+	check := func(name string, err error) {
+		status := &types.ComponentStatus{Ready: err == nil}
+
+		if err != nil {
+			status.Error = err.Error()
+			ready = false
+		}
+
+		components[name] = status
+	}
+
+	check("databases", database.Probe())
+
+	for _, ext := range extensions {
+		status := &types.ComponentStatus{Ready: ext.IsReady()}
+
+		if !status.Ready {
+			status.Error = "not initialized"
+			ready = false
+		}
+
+		components["extension:"+ext.Name()] = status
+	}
+
+	if readyChecksDNS {
+		servers := dnsServerList
+
+		if len(servers) == 0 {
+			servers = dns.DefaultDNSServers
+		}
+
+		anyAnswered := false
+
+		for _, server := range servers {
+			err := dns.Ping(server)
+			status := &types.ComponentStatus{Ready: err == nil}
+
+			if err != nil {
+				status.Error = err.Error()
+			} else {
+				anyAnswered = true
+			}
+
+			components["dns:"+server] = status
+		}
+
+		// A single server being down isn't enough to take the instance out of rotation.
+		ready = ready && anyAnswered
+	}
+
+	response := &types.ApiResponse{
+		Success: ready,
+		Status:  "Ready",
+		Data:    components,
+	}
+
+	if !ready {
+		response.Status = "Not ready"
+		c.JSON(503, response)
+		return
+	}
+
+	c.JSON(200, response)
+}
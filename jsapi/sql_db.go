@@ -1,6 +1,9 @@
 package jsapi
 
 import (
+	"errors"
+	"sync"
+
 	js "github.com/dop251/goja"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -10,6 +13,8 @@ type SqlDB struct {
 	VM      *js.Runtime
 	Proto   *js.Object
 	DataDir string
+	lock    sync.Mutex
+	conns   []*gorm.DB
 }
 
 func (s *SqlDB) Init() {
@@ -26,6 +31,10 @@ func (s *SqlDB) constructor(call js.ConstructorCall) *js.Object {
 		panic("failed to connect database")
 	}
 
+	s.lock.Lock()
+	s.conns = append(s.conns, db)
+	s.lock.Unlock()
+
 	inst := s.VM.CreateObject(s.Proto)
 
 	inst.Set("exec", func(call js.FunctionCall) js.Value {
@@ -89,3 +98,25 @@ func (s *SqlDB) constructor(call js.ConstructorCall) *js.Object {
 
 	return inst
 }
+
+// Close closes every database connection that the extension opened.
+func (s *SqlDB) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var errs []error
+
+	for _, conn := range s.conns {
+		sqlDB, err := conn.DB()
+
+		if err == nil {
+			err = sqlDB.Close()
+		}
+
+		errs = append(errs, err)
+	}
+
+	s.conns = nil
+
+	return errors.Join(errs...)
+}
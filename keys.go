@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/auth"
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+// apiKeyContextKey is where the authenticated key is kept in the gin context.
+const apiKeyContextKey = "apiKey"
+
+// The groups of routes.
+const (
+	groupIP        = "ip"
+	groupDomain    = "domain"
+	groupExtension = "ext"
+	groupAdmin     = "admin"
+)
+
+var keyring = &auth.Keyring{}
+
+// publicScopes are the scopes whose read-only (GET and HEAD) routes can be used without a key.
+var publicScopes = []string{auth.ScopeLookup, auth.ScopeExtensionPrefix + "*"}
+
+// isServiceRoute returns true for the health checks and the API docs, which clients use to find
+// out about the service rather than to look anything up. They're exempt from the access lists and
+// the geo rules, so that load balancers on private addresses can always reach them.
+func isServiceRoute(route string) bool {
+	switch route {
+	case "/healthz", "/readyz", "/api/openapi.json", "/api/docs":
+		return true
+	}
+
+	return false
+}
+
+// routeScope returns the scope needed for a route. The health checks, the API docs, and the routes
+// that weren't matched (e.g. the files in -pub-dir) don't need one.
+func routeScope(route string) string {
+	switch {
+	case route == "" || isServiceRoute(route):
+		return ""
+	case strings.HasPrefix(route, "/api/admin/"):
+		return auth.ScopeAdmin
+	}
+
+	for _, ext := range extensions {
+		prefix := "/api/" + ext.Name()
+
+		if route == prefix || strings.HasPrefix(route, prefix+"/") {
+			return auth.ScopeExtensionPrefix + ext.Name()
+		}
+	}
+
+	return auth.ScopeLookup
+}
+
+// routeGroup returns the group of a route, which rate limits and access policies are set for,
+// along with a more specific name for the routes of each extension (ext:<name>).
+func routeGroup(route string) (string, string) {
+	switch {
+	case strings.HasPrefix(route, "/api/ip_address/"):
+		return groupIP, groupIP
+	case strings.HasPrefix(route, "/api/domain/"):
+		return groupDomain, groupDomain
+	case strings.HasPrefix(route, "/api/admin/"):
+		return groupAdmin, groupAdmin
+	}
+
+	if scope := routeScope(route); strings.HasPrefix(scope, auth.ScopeExtensionPrefix) {
+		return groupExtension, scope
+	}
+
+	return "", ""
+}
+
+// isPublic returns true if the route can be used without a key.
+func isPublic(method, scope string) bool {
+	if scope == "" {
+		return true
+	}
+
+	if method != "GET" && method != "HEAD" {
+		return false
+	}
+
+	for _, public := range publicScopes {
+		if auth.ScopeMatches(public, scope) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// abortUnauthorized aborts a request that failed the authorization with an error response.
+func abortUnauthorized(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, &types.ApiResponse{
+		Success: false,
+		Status:  message,
+	})
+}
+
+// authorize makes sure that the request is allowed to use the route, either anonymously or with
+// a key that has the route's scope. Otherwise it aborts the request and returns false.
+func authorize(c *gin.Context) bool {
+	scope := routeScope(c.FullPath())
+
+	// Clients with a privileged certificate can use every route.
+	if hasPrivilegedClientCert(c) {
+		return true
+	}
+
+	token := c.GetHeader("X-AUTH-TOKEN")
+
+	if len(token) == 0 {
+		if isPublic(c.Request.Method, scope) {
+			return true
+		}
+
+		abortUnauthorized(c, 401, "An API key is required")
+		return false
+	}
+
+	key, err := keyring.Authenticate(token)
+
+	if errors.Is(err, auth.ErrInvalidKey) || errors.Is(err, auth.ErrExpiredKey) {
+		abortUnauthorized(c, 401, "The API key is invalid or expired")
+		return false
+	} else if err != nil {
+		log.Println("Unable to authenticate the API key:", err)
+		abortUnauthorized(c, 500, "Unable to authenticate the API key")
+		return false
+	}
+
+	if !key.AllowsIP(requestClientIP(c)) || !key.HasScope(scope) {
+		abortUnauthorized(c, 403, "The API key isn't allowed to use this route")
+		return false
+	}
+
+	c.Set(apiKeyContextKey, key)
+
+	return true
+}
+
+// runKeys is the entry point of the keys command, which manages the keys in a key store.
+func runKeys(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: geoip-service keys create|list|revoke [flags]")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	storePath := fs.String("key-store", "", "The key store (a JSON file, or a SQLite database if it ends in .db, .sqlite, or .sqlite3)")
+	name := fs.String("name", "", "The name of the key")
+	scopes := fs.String("scopes", auth.ScopeLookup, "A comma separated list of the key's scopes (lookup, admin, ext:<name>, or ext:*)")
+	allow := fs.String("allow", "", "A comma separated list of the CIDR ranges the key can be used from (anywhere if empty)")
+	expires := fs.Duration("expires", 0, "How long the key is valid for, e.g. 720h (never expires if 0)")
+	openAuditLog := addAuditFlags(fs)
+	configPath := fs.String("config", "", "The config file of the server, to read the shared settings (e.g. -key-store) from")
+
+	fs.Parse(args[1:])
+
+	if err := applyCommandSettings(fs, *configPath, "name", "scopes", "allow", "expires"); err != nil {
+		fmt.Println("Config error:", err)
+		os.Exit(1)
+	}
+
+	if err := openAuditLog(); err != nil {
+		fmt.Println("Unable to open the audit log:", err)
+		os.Exit(1)
+	}
+
+	if len(*storePath) == 0 {
+		fmt.Println("The -key-store flag is required")
+		os.Exit(1)
+	}
+
+	store, err := auth.OpenStore(*storePath)
+
+	if err != nil {
+		fmt.Println("Unable to open the key store:", err)
+		os.Exit(1)
+	}
+
+	defer store.Close()
+
+	switch args[0] {
+	case "create":
+		allowedCIDRs := []string{}
+
+		if len(*allow) > 0 {
+			allowedCIDRs = strings.Split(*allow, ",")
+		}
+
+		key, token, err := auth.NewKey(*name, strings.Split(*scopes, ","), allowedCIDRs, *expires)
+
+		if err == nil {
+			err = store.Add(key)
+			audit("key created", err, "name", key.Name, "scopes", key.Scopes, "allowed_cidrs", key.AllowedCIDRs, "expires_at", key.ExpiresAt)
+		}
+
+		if err != nil {
+			fmt.Println("Unable to create the key:", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("API key:", token)
+		fmt.Println("This is the only time the key is shown, so keep it somewhere safe")
+	case "list":
+		keys, err := store.List()
+
+		if err != nil {
+			fmt.Println("Unable to list the keys:", err)
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSCOPES\tALLOWED\tCREATED\tEXPIRES")
+
+		for _, key := range keys {
+			allowed := "any"
+			expiresAt := "never"
+
+			if len(key.AllowedCIDRs) > 0 {
+				allowed = strings.Join(key.AllowedCIDRs, ",")
+			}
+
+			if key.ExpiresAt != nil {
+				expiresAt = key.ExpiresAt.Format(time.RFC3339)
+
+				if key.IsExpired() {
+					expiresAt += " (expired)"
+				}
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.Name, strings.Join(key.Scopes, ","), allowed, key.CreatedAt.Format(time.RFC3339), expiresAt)
+		}
+
+		w.Flush()
+	case "revoke":
+		err := store.Revoke(*name)
+		audit("key revoked", err, "name", *name)
+
+		if err != nil {
+			fmt.Println("Unable to revoke the key:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Revoked %q\n", *name)
+	default:
+		fmt.Printf("Unknown keys command %q\n", args[0])
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+// ParseAcceptLanguage returns the locales of an Accept-Language header, ordered by their quality
+// values. Wildcards and locales with a quality of 0 are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weightedLocale struct {
+		locale  string
+		quality float64
+	}
+
+	weighted := []weightedLocale{}
+
+	for _, part := range strings.Split(header, ",") {
+		locale, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if v, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = v
+			}
+		}
+
+		if len(locale) == 0 || locale == "*" || quality <= 0 {
+			continue
+		}
+
+		weighted = append(weighted, weightedLocale{locale, quality})
+	}
+
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].quality > weighted[j].quality
+	})
+
+	locales := []string{}
+
+	for _, w := range weighted {
+		locales = append(locales, w.locale)
+	}
+
+	return locales
+}
+
+// ParseLocaleList parses a comma separated list of locales (e.g. "de,fr").
+func ParseLocaleList(list string) []string {
+	locales := []string{}
+
+	for _, locale := range strings.Split(list, ",") {
+		if locale = strings.TrimSpace(locale); len(locale) > 0 {
+			locales = append(locales, locale)
+		}
+	}
+
+	return locales
+}
+
+// requestLocales returns the locales a request asked for, with ?lang= taking precedence over the
+// Accept-Language header, and whether all the translations should be kept (?all_names=true).
+func requestLocales(c *gin.Context) ([]string, bool) {
+	allNames := c.Query("all_names") == "true"
+
+	if lang := c.Query("lang"); len(lang) > 0 {
+		return ParseLocaleList(lang), allNames
+	}
+
+	return ParseAcceptLanguage(c.GetHeader("Accept-Language")), allNames
+}
+
+// localizeRecords localizes a list of records for the locales of the request.
+func localizeRecords(c *gin.Context, recs []*types.IPRecord) {
+	locales, allNames := requestLocales(c)
+
+	for _, rec := range recs {
+		rec.Localize(locales, allNames)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/auth"
+	"github.com/wisepythagoras/geoip-service/logging"
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+// auditLog records the admin actions. It discards everything unless -audit-log is set.
+var auditLog = slog.New(slog.DiscardHandler)
+
+// addLogFlags registers the flags of the logs and returns a function that sets up the default
+// logger from them once they are parsed. The standard log package writes through it as well.
+func addLogFlags(fs *flag.FlagSet) func() error {
+	format := fs.String("log-format", "json", "The format of the logs: json or text")
+	level := fs.String("log-level", "info", "The minimum level of the logs: debug, info, warn, or error")
+
+	return func() error {
+		logger, err := logging.New(os.Stderr, *format, *level)
+
+		if err != nil {
+			return err
+		}
+
+		slog.SetDefault(logger)
+
+		return nil
+	}
+}
+
+// addAuditFlags registers the flags of the audit log and returns a function that opens it once
+// they are parsed.
+func addAuditFlags(fs *flag.FlagSet) func() error {
+	path := fs.String("audit-log", "", "The file to record the admin actions in, as JSON lines (disabled if empty)")
+	maxSize := fs.Int("audit-log-max-size", 100, "The size (in MB) at which the audit log is rotated")
+	maxBackups := fs.Int("audit-log-max-backups", 5, "The number of rotated audit logs to keep")
+
+	return func() error {
+		if len(*path) == 0 {
+			return nil
+		}
+
+		file, err := logging.OpenRotatingFile(*path, int64(*maxSize)*1024*1024, *maxBackups)
+
+		if err != nil {
+			return err
+		}
+
+		auditLog = slog.New(slog.NewJSONHandler(file, nil))
+
+		return nil
+	}
+}
+
+// audit records an admin action, along with its error if it failed.
+func audit(action string, err error, attrs ...any) {
+	if err != nil {
+		auditLog.Error(action, append(attrs, "error", err.Error())...)
+		return
+	}
+
+	auditLog.Info(action, attrs...)
+}
+
+// auditRequest records an admin action that was made through the API, along with who made it.
+func auditRequest(c *gin.Context, action string, err error, attrs ...any) {
+	attrs = append(attrs, "client_ip", requestClientIP(c).String())
+
+	if name := requestKeyName(c); len(name) > 0 {
+		attrs = append(attrs, "api_key", name)
+	}
+
+	audit(action, err, attrs...)
+}
+
+// requestKeyName returns the name of the API key the request was made with, if any. Only the
+// name is ever logged, never the key itself.
+func requestKeyName(c *gin.Context) string {
+	if value, found := c.Get(apiKeyContextKey); found {
+		return value.(*auth.Key).Name
+	}
+
+	return ""
+}
+
+// logMiddleware logs every request once it's handled, along with where the client is from.
+func logMiddleware(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	status := c.Writer.Status()
+	route := c.FullPath()
+
+	if len(route) == 0 {
+		route = "unmatched"
+	}
+
+	clientIP := requestClientIP(c)
+	attrs := []slog.Attr{
+		slog.String("method", c.Request.Method),
+		slog.String("route", route),
+		slog.String("path", c.Request.URL.Path),
+		slog.Int("status", status),
+		slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.Int("bytes", max(c.Writer.Size(), 0)),
+		slog.String("client_ip", clientIP.String()),
+	}
+
+	// The geo rules may have looked up the client already.
+	var rec *types.IPRecord
+
+	if value, found := c.Get(clientRecordContextKey); found {
+		rec = value.(*types.IPRecord)
+	} else if database != nil && clientIP != nil {
+		rec, _ = database.Lookup(clientIP)
+	}
+
+	if rec != nil && len(rec.Country.ISOCode) > 0 {
+		attrs = append(attrs, slog.String("country", rec.Country.ISOCode))
+	}
+
+	if rec != nil && rec.ASN > 0 {
+		attrs = append(attrs, slog.Int("asn", rec.ASN))
+	}
+
+	if name := requestKeyName(c); len(name) > 0 {
+		attrs = append(attrs, slog.String("api_key", name))
+	}
+
+	level := slog.LevelInfo
+
+	if status >= 500 {
+		level = slog.LevelError
+	} else if status >= 400 {
+		level = slog.LevelWarn
+	}
+
+	slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+}
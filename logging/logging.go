@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// New creates a logger that writes to w in the given format (json or text), starting at the given
+// level (debug, info, warn, or error).
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var minLevel slog.Level
+
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	options := &slog.HandlerOptions{Level: minLevel}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	}
+
+	return nil, fmt.Errorf("invalid log format %q (expected json or text)", format)
+}
+
+// RotatingFile is a log file that's rotated once it grows past MaxSize: the current file is
+// renamed to path.1, the previous path.1 to path.2, and so on, keeping MaxBackups of them.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+	lock       sync.Mutex
+	file       *os.File
+	size       int64
+	// retrySize is the size at which to try rotating again, after a rotation failed.
+	retrySize int64
+}
+
+// OpenRotatingFile opens (or creates) the log file, appending to it.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+// rotate shifts the backups and starts a new file. It must be called with the lock held. The
+// current file is only closed once the new one is open, so if anything fails the entries keep
+// going to the current file rather than being lost.
+func (f *RotatingFile) rotate() error {
+	current := f.file
+	moved := f.Path + ".rotating"
+
+	// The current file is moved out of the way first, so that the backups are only shifted once
+	// it's certain that it can take the place of the first one.
+	if err := os.Rename(f.Path, moved); err != nil {
+		return err
+	}
+
+	if f.MaxBackups > 0 {
+		for i := f.MaxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.Path, i), fmt.Sprintf("%s.%d", f.Path, i+1))
+		}
+
+		if err := os.Rename(moved, f.Path+".1"); err != nil {
+			os.Rename(moved, f.Path)
+			return err
+		}
+	} else {
+		os.Remove(moved)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	current.Close()
+
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > max(f.MaxSize, f.retrySize) {
+		if err := f.rotate(); err != nil {
+			// Trying again on every write would only fail the same way, so the next attempt waits
+			// until the file grows by another MaxSize.
+			log.Println("Unable to rotate the log file:", err)
+			f.retrySize = f.size + f.MaxSize
+		} else {
+			f.retrySize = 0
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.file.Close()
+}
@@ -9,108 +9,177 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
-	"github.com/wisepythagoras/geoip-service/crypto"
+	"github.com/go-co-op/gocron"
+	"github.com/wisepythagoras/geoip-service/auth"
+	"github.com/wisepythagoras/geoip-service/cache"
 	"github.com/wisepythagoras/geoip-service/db"
 	"github.com/wisepythagoras/geoip-service/dns"
 	"github.com/wisepythagoras/geoip-service/extension"
+	"github.com/wisepythagoras/geoip-service/metrics"
+	"github.com/wisepythagoras/geoip-service/proxyproto"
 	"github.com/wisepythagoras/geoip-service/types"
 )
 
 var database *db.DB
 var err error
-var whiteListedIPRanges []*net.IPNet
-var whiteListedIPs []net.IP
-var hasWhitelist = false
 var dnsServerList = []string{}
 var extensions []*extension.Extension
-var appAPIKey string
+var maxBatchSize = 100
 
 func middleware(c *gin.Context) {
-	apiKey := c.GetHeader("X-AUTH-TOKEN")
-	method := c.Request.Method
-	requiresAPIKey := method == "POST" || method == "PUT" || method == "DELETE"
-
-	// If there was no whitelist specified, then we can proceed.
-	if !hasWhitelist {
-		if (len(apiKey) == 0 || apiKey != appAPIKey) && requiresAPIKey {
-			c.AbortWithStatus(401)
-			return
-		}
-
-		c.Next()
+	if !checkAccess(c) || !authorize(c) || !checkGeoRules(c) {
 		return
 	}
 
-	clientIP := net.ParseIP(c.ClientIP())
+	c.Next()
+}
 
-	if val := c.GetHeader("True-Client-IP"); len(val) > 0 {
-		clientIP = net.ParseIP(val)
-	}
+func IPAddressHandler(c *gin.Context) {
+	hostname := c.Param("hostname")
+	clientIP := requestClientIP(c)
+	response := &types.ApiResponse{}
+	response.Data = nil
+
+	// Is this a valid IP address?
+	if !IsValidIP(hostname) {
+		response.Success = false
+		response.Status = "Invalid input"
+
+		c.JSON(500, response)
 
-	if (len(apiKey) == 0 || apiKey != appAPIKey) && requiresAPIKey {
-		c.AbortWithStatus(401)
 		return
 	}
 
-	// Otherwise we need to check both list of IPs and IP ranges.
-	if sliceContains(whiteListedIPs, clientIP) {
-		c.Next()
+	response.Success = true
+	response.Status = "Retrieved"
+
+	// Get the IP information for this.
+	rec, err := database.GetIPInformation(hostname, &clientIP)
+
+	if err != nil {
+		response.Status = err.Error()
+		c.JSON(200, response)
 		return
 	}
 
-	for _, ipRange := range whiteListedIPRanges {
-		if ipRange.Contains(clientIP) {
-			c.Next()
-			return
+	// The reverse DNS lookup is opt-in, since it's much slower than the database lookup.
+	confirm := c.Query("fcrdns") == "true"
+
+	if c.Query("ptr") == "true" || confirm {
+		rec.PTR, err = dns.ReverseLookup(net.ParseIP(hostname), dnsServerList, confirm)
+
+		if err != nil {
+			response.Status = err.Error()
 		}
 	}
 
-	// If the client's IP address was not found in the whitelisted IPs, then we should deny access.
-	c.AbortWithStatus(400)
-}
+	localizeRecords(c, []*types.IPRecord{rec})
+	response.Data = rec
 
-// https://github.com/allegro/bigcache
+	c.JSON(200, response)
+}
 
-func IPAddressHandler(c *gin.Context) {
-	hostname := c.Param("hostname")
-	clientIPStr := c.ClientIP()
-	clientIP := net.ParseIP(clientIPStr)
+// BatchIPAddressHandler looks up a JSON array of IP addresses in one request. Each address gets
+// its own result entry, so invalid or failed lookups don't fail the entire batch.
+func BatchIPAddressHandler(c *gin.Context) {
+	clientIP := requestClientIP(c)
 	response := &types.ApiResponse{}
 	response.Data = nil
 
-	// Is this a valid IP address?
-	if !IsValidIP(hostname) {
+	var ips []string
+
+	if err := c.ShouldBindJSON(&ips); err != nil {
 		response.Success = false
 		response.Status = "Invalid input"
 
-		c.JSON(500, response)
+		c.JSON(400, response)
 
 		return
 	}
 
+	if len(ips) > maxBatchSize {
+		response.Success = false
+		response.Status = fmt.Sprintf("Too many addresses (the maximum is %d)", maxBatchSize)
+
+		c.JSON(413, response)
+
+		return
+	}
+
+	results := make([]*types.BatchIPResult, len(ips))
+
+	for i, ip := range ips {
+		result := &types.BatchIPResult{IPAddress: ip}
+		results[i] = result
+
+		if !IsValidIP(ip) {
+			result.Status = "Invalid input"
+			continue
+		}
+
+		rec, err := database.GetIPInformation(ip, &clientIP)
+
+		if err != nil {
+			result.Status = err.Error()
+			continue
+		}
+
+		result.Success = true
+		result.Status = "Retrieved"
+		result.Data = rec
+		localizeRecords(c, []*types.IPRecord{rec})
+	}
+
 	response.Success = true
 	response.Status = "Retrieved"
+	response.Data = results
 
-	// Get the IP information for this.
-	response.Data, err = database.GetIPInformation(hostname, &clientIP)
+	c.JSON(200, response)
+}
 
-	if err != nil {
+func FastDomainHandler(c *gin.Context) {
+	hostname := c.Param("hostname")
+	clientIP := requestClientIP(c)
+	response := &types.ApiResponse{}
+	recs, err := database.GetDomainInformation(hostname, dnsServerList, &clientIP)
+	localizeRecords(c, recs)
+	response.Data = recs
+
+	if err == nil {
+		response.Success = true
+		response.Status = "Retrieved"
+	} else {
+		response.Success = false
 		response.Status = err.Error()
 	}
 
 	c.JSON(200, response)
 }
 
-func FastDomainHandler(c *gin.Context) {
+func DomainHandler(c *gin.Context) {
 	hostname := c.Param("hostname")
-	clientIPStr := c.ClientIP()
-	clientIP := net.ParseIP(clientIPStr)
+	clientIP := requestClientIP(c)
 	response := &types.ApiResponse{}
-	response.Data, err = database.GetDomainInformation(hostname, dnsServerList, &clientIP)
+	caller, err := dns.CallerForFamily(c.DefaultQuery("family", "4"))
+
+	if err != nil {
+		response.Success = false
+		response.Status = err.Error()
+
+		c.JSON(400, response)
+
+		return
+	}
+
+	recs, err := database.GetDomainInfoFromDNS(hostname, dnsServerList, caller, &clientIP)
+	localizeRecords(c, recs)
+	response.Data = recs
 
 	if err == nil {
 		response.Success = true
@@ -123,12 +192,30 @@ func FastDomainHandler(c *gin.Context) {
 	c.JSON(200, response)
 }
 
-func DomainHandler(c *gin.Context) {
+// DomainRecordsHandler returns the structured DNS records of a domain, with any IP addresses they
+// point to geolocated. The record types are picked with ?type=MX,NS,TXT (defaults to all).
+func DomainRecordsHandler(c *gin.Context) {
 	hostname := c.Param("hostname")
-	clientIPStr := c.ClientIP()
-	clientIP := net.ParseIP(clientIPStr)
+	clientIP := requestClientIP(c)
 	response := &types.ApiResponse{}
-	response.Data, err = database.GetDomainInfoFromDNS(hostname, dnsServerList, dns.DNSALookup, &clientIP)
+	recordTypes, err := dns.ParseRecordTypes(c.Query("type"))
+
+	if err != nil {
+		response.Success = false
+		response.Status = err.Error()
+
+		c.JSON(400, response)
+
+		return
+	}
+
+	records, err := database.GetDomainRecords(hostname, recordTypes, dnsServerList, &clientIP)
+
+	for _, record := range records {
+		localizeRecords(c, record.Addresses)
+	}
+
+	response.Data = records
 
 	if err == nil {
 		response.Success = true
@@ -141,6 +228,53 @@ func DomainHandler(c *gin.Context) {
 	c.JSON(200, response)
 }
 
+// ReloadDatabasesHandler swaps in the database files currently on disk, without restarting.
+func ReloadDatabasesHandler(c *gin.Context) {
+	response := &types.ApiResponse{}
+
+	err := database.Reload()
+	auditRequest(c, "databases reloaded", err, "trigger", "api")
+
+	if err != nil {
+		response.Success = false
+		response.Status = err.Error()
+
+		c.JSON(500, response)
+
+		return
+	}
+
+	response.Success = true
+	response.Status = "Reloaded"
+
+	c.JSON(200, response)
+}
+
+// DatabasesHandler lists the loaded databases and their metadata.
+func DatabasesHandler(c *gin.Context) {
+	response := &types.ApiResponse{
+		Success: true,
+		Status:  "Retrieved",
+		Data:    database.Databases(),
+	}
+
+	c.JSON(200, response)
+}
+
+// CacheStatsHandler returns the counters of the IP and DNS caches.
+func CacheStatsHandler(c *gin.Context) {
+	response := &types.ApiResponse{
+		Success: true,
+		Status:  "Retrieved",
+		Data: map[string]cache.Stats{
+			"ip":  database.Cache.Stats(),
+			"dns": dns.Cache.Stats(),
+		},
+	}
+
+	c.JSON(200, response)
+}
+
 func DNSServers(c *gin.Context) {
 	response := &types.ApiResponse{
 		Success: true,
@@ -156,18 +290,122 @@ func DNSServers(c *gin.Context) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "update-db" {
+		runUpdateDB(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		runKeys(os.Args[2:])
+		return
+	}
+
 	domainPtr := flag.String("domain", "", "A domain name")
+	familyPtr := flag.String("family", "", "The address family to resolve with -domain: 4, 6, or any (uses the system resolver if not specified)")
 	ipPtr := flag.String("ip", "", "An IP address")
+	langPtr := flag.String("lang", types.DefaultLocale, "A comma separated list of the preferred locales for the place names of -ip and -domain")
+	allNamesPtr := flag.Bool("all-names", false, "Include the names in every locale in the -ip and -domain output")
+	ptrPtr := flag.Bool("ptr", false, "Add the reverse DNS (PTR) records to the -ip lookup")
+	fcrdnsPtr := flag.Bool("fcrdns", false, "Forward-confirm the reverse DNS records of the -ip lookup (implies -ptr)")
 	shouldServe := flag.Bool("serve", false, "Run the HTTP server")
 	serveIP := flag.String("sip", "127.0.0.1", "The IP to serve on (127.0.0.1 will make it accessible only from localhost)")
-	whitelist := flag.String("whitelist", "", "If specified, it will only allow access to the IPs in the list (only used with -serve)")
+	servePort := flag.Int("port", 8228, "The port to serve on")
+	listenAddr := flag.String("listen", "", "The address to serve on, as host:port or unix:/path/to.sock (overrides -sip and -port)")
+	readTimeout := flag.Duration("read-timeout", 30*time.Second, "The maximum duration for reading a request, including its body")
+	writeTimeout := flag.Duration("write-timeout", 60*time.Second, "The maximum duration for writing a response")
+	idleTimeout := flag.Duration("idle-timeout", 120*time.Second, "How long to keep idle keep-alive connections open")
+	tlsCert := flag.String("tls-cert", "", "The TLS certificate to serve HTTPS with (requires -tls-key)")
+	tlsKey := flag.String("tls-key", "", "The private key of the TLS certificate")
+	tlsMinVersion := flag.String("tls-min-version", "1.2", "The minimum TLS version to accept: 1.0, 1.1, 1.2, or 1.3")
+	tlsReload := flag.Duration("tls-reload-interval", time.Minute, "How often to check the certificate files for changes (0 only reloads on SIGHUP)")
+	tlsClientCA := flag.String("tls-client-ca", "", "The CA certificates to verify client certificates with (enables mutual TLS)")
+	tlsRequireClientCert := flag.Bool("tls-require-client-cert", false, "Reject clients that don't present a valid certificate (requires -tls-client-ca)")
+	tlsPrivilegedSubjects := flag.String("tls-privileged-subjects", "", "A file with the client certificate subjects that get the same privileges as the API key (requires -tls-client-ca)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "How long to wait for in-flight requests to finish when shutting down")
 	dnsServers := flag.String("dns-servers", "", "The list of DNS servers. If not specified defaults to Cloudflare, Google, and OpenDNS")
 	publicFolder := flag.String("pub-dir", "", "Specify the location of the public folder (to serve a front end)")
 	extFolder := flag.String("ext-dir", "", "Specify the location of the folder containing the extensions")
-	apiKey := flag.String("api-key", "", "Specify an API key to protect your instance (it will be generated if you don't specify one)")
+	apiKey := flag.String("api-key", "", "An API key with the admin scope (one is generated if neither this nor -key-store is specified)")
+	keyStorePath := flag.String("key-store", "", "The key store with the named API keys (a JSON file, or a SQLite database if it ends in .db, .sqlite, or .sqlite3)")
+	publicScopesList := flag.String("public-scopes", strings.Join(publicScopes, ","), "A comma separated list of the scopes whose GET routes can be used without an API key")
+	dbWatch := flag.Duration("db-watch", 0, "How often to check the databases for changes and reload them, e.g. 1m (only used with -serve)")
+	updateCron := flag.String("update-cron", "", "A cron expression for updating the databases while serving, e.g. \"0 4 * * 3\" (only used with -serve)")
+	newUpdater := addUpdaterFlags(flag.CommandLine)
+	dbDefs := &listFlag{}
+	flag.Var(dbDefs, "db", "A database to use, as kind=path or custom:name=path (can be repeated; defaults to the GeoLite2 City and ASN databases in ./geolite)")
+	dnsServerDefs := &listFlag{}
+	flag.Var(dnsServerDefs, "dns-server", "A DNS server to query, in addition to the ones in -dns-servers (can be repeated)")
+	rateLimitDefs := &listFlag{}
+	flag.Var(rateLimitDefs, "rate-limit", "A rate limit per client IP for a route group (ip, domain, or ext), as group=count/unit[:burst], e.g. domain=10/s:20 (can be repeated)")
+	keyRateLimitDefs := &listFlag{}
+	flag.Var(keyRateLimitDefs, "key-rate-limit", "A rate limit per API key for a route group, in the same format as -rate-limit (can be repeated)")
+	trustedProxiesList := flag.String("trusted-proxies", "127.0.0.1,::1", "A comma separated list of the IPs and CIDR ranges of the proxies whose client IP headers are trusted (none if empty)")
+	clientIPHeaders := flag.String("client-ip-headers", "X-Forwarded-For,X-Real-IP", "A comma separated list of the headers to take the client IP from, in order of priority, when the request comes from a trusted proxy")
+	proxyProtocol := flag.Bool("proxy-protocol", false, "Read the PROXY protocol header (v1 or v2) of the connections from trusted proxies")
+	geoRuleDefs := &listFlag{}
+	flag.Var(geoRuleDefs, "geo-rule", "A geo-fencing rule, as [group=]action:field=values or [group=]action:field!=values, e.g. deny:country=CN,RU (can be repeated)")
+	accessWatch := flag.Duration("access-watch", 10*time.Second, "How often to check the allow and deny lists for changes (0 only reloads them on SIGHUP)")
+	loadAccessLists := addAccessFlags(flag.CommandLine)
+	setupCORS := addCORSFlags(flag.CommandLine)
+	configPath := flag.String("config", "", "A YAML, TOML, or JSON file with the settings, keyed by the flag names (flags and GEOIP_* environment variables take precedence)")
+	printConfig := flag.Bool("print-config", false, "Print the effective settings as YAML and exit")
+	ipCacheMem := flag.Int("ip-cache-mem", 0, "The memory (in MB) to use for caching IP lookups (0 disables the cache)")
+	ipCacheTTL := flag.Duration("ip-cache-ttl", 10*time.Minute, "How long to cache the database lookups of IP addresses for (the lookup extensions always run)")
+	dnsCacheMem := flag.Int("dns-cache-mem", 0, "The memory (in MB) to use for caching DNS responses, which are kept for their TTL (0 disables the cache)")
+	readyDNS := flag.Bool("ready-dns", false, "Require at least one DNS server to answer for the instance to be ready (only used with -serve)")
+	batchSize := flag.Int("max-batch", 100, "The maximum number of IP addresses accepted by the batch lookup endpoint")
+
+	setupLogs := addLogFlags(flag.CommandLine)
+	openAuditLog := addAuditFlags(flag.CommandLine)
 
 	flag.Parse()
 
+	var fileSettings map[string]any
+
+	if fileSettings, err = readConfig(*configPath); err != nil {
+		fmt.Println("Config error:", err)
+		os.Exit(1)
+	}
+
+	if err = ApplySettings(flag.CommandLine, fileSettings, "config", "print-config"); err != nil {
+		fmt.Println("Config error:", err)
+		os.Exit(1)
+	}
+
+	if *printConfig {
+		if err = PrintSettings(flag.CommandLine, "config", "print-config"); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if err = setupLogs(); err != nil {
+		fmt.Println("Log error:", err)
+		os.Exit(1)
+	}
+
+	if err = openAuditLog(); err != nil {
+		fmt.Println("Unable to open the audit log:", err)
+		os.Exit(1)
+	}
+
+	dbSources := []*db.Source{}
+
+	for _, def := range dbDefs.values {
+		source, err := db.ParseSource(def)
+
+		if err != nil {
+			fmt.Println("Database error:", err)
+			os.Exit(1)
+		}
+
+		dbSources = append(dbSources, source)
+	}
+
+	locales := ParseLocaleList(*langPtr)
+
 	if len(*extFolder) > 0 {
 		extensions, err = parseExtensions(*extFolder)
 
@@ -187,7 +425,23 @@ func main() {
 	}
 
 	// Open the city database.
-	database = &db.DB{Extensions: extensions}
+	database = &db.DB{
+		Sources:    dbSources,
+		Extensions: extensions,
+		CacheTTL:   *ipCacheTTL,
+		OnReload: func(trigger string, err error) {
+			audit("databases reloaded", err, "trigger", trigger)
+		},
+	}
+
+	if *ipCacheMem > 0 {
+		database.Cache = db.NewCache(*ipCacheMem << 20)
+	}
+
+	if *dnsCacheMem > 0 {
+		dns.Cache = dns.NewCache(*dnsCacheMem << 20)
+	}
+
 	err := database.Open()
 
 	if err != nil {
@@ -211,53 +465,156 @@ func main() {
 		}
 	}
 
+	for _, server := range dnsServerDefs.values {
+		dnsServerList = append(dnsServerList, withDefaultDNSPort(server))
+	}
+
 	if *shouldServe {
-		if len(*apiKey) > 0 {
-			appAPIKey = *apiKey
-		} else {
-			randBytes, err := crypto.GenRandomBytes(32, time.Now().Unix())
+		if *batchSize < 1 {
+			fmt.Println("The maximum batch size needs to be at least 1")
+			os.Exit(1)
+		}
+
+		maxBatchSize = *batchSize
+		readyChecksDNS = *readyDNS
+
+		// The databases can be swapped in on a SIGHUP, on a call to the admin endpoint, or when
+		// the files change if watching was enabled.
+		database.ReloadOnSignal()
+
+		if *dbWatch > 0 {
+			database.Watch(*dbWatch)
+		}
+
+		if len(*updateCron) > 0 {
+			u := newUpdater()
+
+			// The updates are installed in -update-dir, so a database that's read from anywhere
+			// else would never be updated. Custom databases don't come from the updater.
+			for _, source := range database.Sources {
+				if source.Kind != db.KindCustom && !u.Installs(source.Path) {
+					fmt.Printf("Invalid update schedule: the %s database is read from %q, which isn't one of the editions installed in %q\n", source.Name, source.Path, u.Dir)
+					os.Exit(1)
+				}
+			}
+
+			updateScheduler = gocron.NewScheduler(time.UTC)
+			_, err := updateScheduler.Cron(*updateCron).Do(func() {
+				err := u.Update()
+				audit("databases updated", err, "trigger", "schedule", "editions", u.Editions)
+
+				if err != nil {
+					log.Println("Database update error:", err)
+				}
+
+				// Some editions may have been updated even if others failed.
+				err = database.Reload()
+				audit("databases reloaded", err, "trigger", "schedule")
+
+				if err != nil {
+					log.Println("Unable to reload the databases:", err)
+				}
+			})
 
 			if err != nil {
-				fmt.Println("Error:", err)
+				fmt.Println("Invalid update schedule:", err)
+				os.Exit(1)
+			}
+
+			updateScheduler.StartAsync()
+		}
+
+		if len(*keyStorePath) > 0 {
+			keyring.Store, err = auth.OpenStore(*keyStorePath)
+
+			if err != nil {
+				fmt.Println("Unable to open the key store:", err)
 				os.Exit(1)
 			}
+		}
 
-			hashBytes, err := crypto.GetSHA256Hash(randBytes)
+		if len(*apiKey) > 0 {
+			keyring.AddStatic("default", *apiKey, auth.ScopeAdmin)
+		} else if keyring.Store == nil {
+			token, err := auth.NewToken()
 
 			if err != nil {
 				fmt.Println("Error:", err)
 				os.Exit(1)
 			}
 
-			appAPIKey = crypto.ByteArrayToHex(hashBytes)
+			keyring.AddStatic("default", token, auth.ScopeAdmin)
+
+			fmt.Println("API key:", token)
+			fmt.Println("This API key has the admin scope, which gives access to every endpoint")
 		}
 
-		fmt.Println("API key:", appAPIKey)
-		fmt.Println("This API key should be used to access any non-GET endpoint")
+		if clientLimiters, err = parseRateLimits(rateLimitDefs.values); err != nil {
+			fmt.Println("Rate limit error:", err)
+			os.Exit(1)
+		}
 
-		if len(*whitelist) > 0 {
-			file, err := os.Open(*whitelist)
+		if keyLimiters, err = parseRateLimits(keyRateLimitDefs.values); err != nil {
+			fmt.Println("Rate limit error:", err)
+			os.Exit(1)
+		}
 
-			if err != nil {
-				fmt.Println("Unable to open the specified whitelist file")
+		publicScopes = []string{}
+
+		for _, scope := range strings.Split(*publicScopesList, ",") {
+			if scope = strings.TrimSpace(scope); len(scope) == 0 {
+				continue
+			}
+
+			if err := auth.ValidateScope(scope); err != nil {
+				fmt.Println("Invalid public scope:", err)
 				os.Exit(1)
 			}
 
-			defer file.Close()
-			whiteListedIPRanges, whiteListedIPs, err = ParseIPList(file)
+			publicScopes = append(publicScopes, scope)
+		}
 
-			if err != nil {
-				fmt.Println("Error while parsing the whitelist", err)
-				os.Exit(1)
+		if err = loadAccessLists(); err != nil {
+			fmt.Println("Unable to load the access lists:", err)
+			os.Exit(1)
+		}
+
+		if !accessPolicy.IsEmpty() {
+			accessPolicy.OnReload = func(trigger string, err error) {
+				audit("access lists reloaded", err, "trigger", trigger)
 			}
 
-			hasWhitelist = true
+			accessPolicy.Watch(*accessWatch)
+		}
+
+		if err = setupCORS(); err != nil {
+			fmt.Println("CORS error:", err)
+			os.Exit(1)
+		}
+
+		if geoRules, err = parseGeoRules(geoRuleDefs.values); err != nil {
+			fmt.Println("Geo rule error:", err)
+			os.Exit(1)
 		}
 
 		// Run a server exposing two endpoints that are query-able.
-		r := gin.Default()
+		r := gin.New()
+		r.Use(logMiddleware, gin.Recovery())
 
+		if trustedProxies, err = ParseTrustedProxies(*trustedProxiesList); err != nil {
+			fmt.Println("Invalid trusted proxy:", err)
+			os.Exit(1)
+		}
+
+		if err = configureClientIP(r, splitList(*clientIPHeaders)); err != nil {
+			fmt.Println("Invalid trusted proxy:", err)
+			os.Exit(1)
+		}
+
+		r.Use(metricsMiddleware)
+		r.Use(corsMiddleware)
 		r.Use(middleware)
+		r.Use(rateLimitMiddleware)
 
 		r.NoRoute(func(c *gin.Context) {
 			if len(*publicFolder) > 0 {
@@ -302,9 +659,19 @@ func main() {
 		}
 
 		r.GET("/api/ip_address/info/:hostname", IPAddressHandler)
+		r.POST("/api/ip_address/batch", BatchIPAddressHandler)
 		r.GET("/api/domain/fast_info/:hostname", FastDomainHandler)
 		r.GET("/api/domain/info/:hostname", DomainHandler)
+		r.GET("/api/domain/records/:hostname", DomainRecordsHandler)
 		r.GET("/api/dns_servers", DNSServers)
+		r.GET("/api/databases", DatabasesHandler)
+		r.GET("/api/cache_stats", CacheStatsHandler)
+		r.GET("/metrics", gin.WrapH(metrics.Default))
+		r.GET("/healthz", HealthHandler)
+		r.GET("/readyz", ReadyHandler)
+		r.POST("/api/admin/reload_databases", ReloadDatabasesHandler)
+		r.GET("/api/openapi.json", OpenAPIHandler)
+		r.GET("/api/docs", APIDocsHandler)
 
 		// Register any endpoint extensions.
 		for _, ext := range extensions {
@@ -315,15 +682,120 @@ func main() {
 			ext.RegisterEndpoints(r)
 		}
 
-		http.ListenAndServe(fmt.Sprintf("%s:8228", *serveIP), r)
+		if openAPIDocument, err = buildOpenAPI(); err != nil {
+			fmt.Println("Unable to build the OpenAPI document:", err)
+			os.Exit(1)
+		}
+
+		address := *listenAddr
+
+		if len(address) == 0 {
+			address = net.JoinHostPort(*serveIP, strconv.Itoa(*servePort))
+		}
+
+		ln, err := listen(address)
+
+		if err != nil {
+			fmt.Println("Unable to listen:", err)
+			os.Exit(1)
+		}
+
+		if *proxyProtocol {
+			ln = &proxyproto.Listener{Listener: ln, Trusted: isTrustedProxy, Timeout: *readTimeout}
+		}
+
+		srv := &http.Server{
+			Handler:      r,
+			ReadTimeout:  *readTimeout,
+			WriteTimeout: *writeTimeout,
+			IdleTimeout:  *idleTimeout,
+		}
+
+		if len(*tlsCert) > 0 || len(*tlsKey) > 0 {
+			reloader, err := newCertReloader(*tlsCert, *tlsKey)
+
+			if err != nil {
+				fmt.Println("Unable to load the TLS certificate:", err)
+				os.Exit(1)
+			}
+
+			srv.TLSConfig, err = newTLSConfig(reloader, *tlsMinVersion, *tlsClientCA, *tlsRequireClientCert)
+
+			if err != nil {
+				fmt.Println("TLS error:", err)
+				os.Exit(1)
+			}
+
+			reloader.watch(*tlsReload)
+		}
+
+		if len(*tlsPrivilegedSubjects) > 0 {
+			// Without mutual TLS no certificate is ever verified, so the subjects would be ignored.
+			if len(*tlsClientCA) == 0 || srv.TLSConfig == nil {
+				fmt.Println("TLS error: the privileged subjects need mutual TLS (-tls-cert, -tls-key, and -tls-client-ca)")
+				os.Exit(1)
+			}
+
+			file, err := os.Open(*tlsPrivilegedSubjects)
+
+			if err != nil {
+				fmt.Println("Unable to open the specified subject list")
+				os.Exit(1)
+			}
+
+			defer file.Close()
+			privilegedSubjects, err = ParseSubjectList(file)
+
+			if err != nil {
+				fmt.Println("Error while reading the subject list", err)
+				os.Exit(1)
+			}
+		}
+
+		if err = runServer(srv, ln, *shutdownTimeout); err != nil {
+			fmt.Println("Server error:", err)
+			os.Exit(1)
+		}
 	} else if *domainPtr != "" {
+		var recs []*types.IPRecord
+
 		// Grab the domain information.
-		recs, _ := database.GetDomainInformation(*domainPtr, dnsServerList, nil)
+		if len(*familyPtr) > 0 {
+			caller, err := dns.CallerForFamily(*familyPtr)
+
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			recs, _ = database.GetDomainInfoFromDNS(*domainPtr, dnsServerList, caller, nil)
+		} else {
+			recs, _ = database.GetDomainInformation(*domainPtr, dnsServerList, nil)
+		}
+
+		for _, rec := range recs {
+			rec.Localize(locales, *allNamesPtr)
+		}
+
 		obj, _ := json.Marshal(recs)
 		fmt.Println(string(obj))
 	} else if *ipPtr != "" {
 		// Grab the information about the sole IP address.
 		rec, _ := database.GetIPInformation(*ipPtr, nil)
+
+		if rec != nil && (*ptrPtr || *fcrdnsPtr) {
+			rec.PTR, err = dns.ReverseLookup(net.ParseIP(*ipPtr), dnsServerList, *fcrdnsPtr)
+
+			if err != nil {
+				fmt.Println("Reverse DNS error:", err)
+				os.Exit(1)
+			}
+		}
+
+		if rec != nil {
+			rec.Localize(locales, *allNamesPtr)
+		}
+
 		obj, _ := json.Marshal(rec)
 		fmt.Println(string(obj))
 	} else {
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/cache"
+	"github.com/wisepythagoras/geoip-service/dns"
+	"github.com/wisepythagoras/geoip-service/metrics"
+)
+
+var (
+	requestCount = metrics.NewCounterVec(
+		"geoip_http_requests_total",
+		"The number of HTTP requests, by route, method, and status code.",
+		"route", "method", "status",
+	)
+	requestDuration = metrics.NewHistogramVec(
+		"geoip_http_request_duration_seconds",
+		"The time it took to handle an HTTP request.",
+		metrics.DefaultBuckets,
+		"route", "method",
+	)
+)
+
+func init() {
+	metrics.NewGaugeFunc(
+		"geoip_database_build_age_seconds",
+		"The time since each loaded database was built.",
+		[]string{"database", "database_type"},
+		func(set func(float64, ...string)) {
+			if database == nil {
+				return
+			}
+
+			for _, info := range database.Databases() {
+				set(time.Since(info.BuiltAt).Seconds(), info.Name, info.DatabaseType)
+			}
+		},
+	)
+	metrics.NewCounterFunc(
+		"geoip_cache_hits_total",
+		"The number of lookups answered from a cache.",
+		[]string{"cache"},
+		func(set func(float64, ...string)) {
+			collectCacheStats(set, func(s cache.Stats) float64 { return float64(s.Hits) })
+		},
+	)
+	metrics.NewCounterFunc(
+		"geoip_cache_misses_total",
+		"The number of lookups that weren't found in a cache.",
+		[]string{"cache"},
+		func(set func(float64, ...string)) {
+			collectCacheStats(set, func(s cache.Stats) float64 { return float64(s.Misses) })
+		},
+	)
+	metrics.NewGaugeFunc(
+		"geoip_cache_size_bytes",
+		"The approximate memory used by a cache.",
+		[]string{"cache"},
+		func(set func(float64, ...string)) {
+			collectCacheStats(set, func(s cache.Stats) float64 { return float64(s.Size) })
+		},
+	)
+}
+
+func collectCacheStats(set func(float64, ...string), value func(cache.Stats) float64) {
+	if database != nil {
+		set(value(database.Cache.Stats()), "ip")
+	}
+
+	set(value(dns.Cache.Stats()), "dns")
+}
+
+// metricsMiddleware counts the requests and their latency per route. Requests that don't match
+// a route are grouped together, so that random paths don't create new series.
+func metricsMiddleware(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	route := c.FullPath()
+
+	if len(route) == 0 {
+		route = "unmatched"
+	}
+
+	method := c.Request.Method
+	requestCount.With(route, method, strconv.Itoa(c.Writer.Status())).Inc()
+	requestDuration.With(route, method).Observe(time.Since(start).Seconds())
+}
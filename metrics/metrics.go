@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets (in seconds) used for latencies.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is a metric family that can write itself in the Prometheus text format.
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds a set of metrics and exposes them to Prometheus.
+type Registry struct {
+	lock       sync.Mutex
+	collectors []collector
+}
+
+// Default is the registry that the New* functions register metrics with.
+var Default = &Registry{}
+
+func (r *Registry) register(c collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// ServeHTTP writes every metric in the Prometheus text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.lock.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].name() < collectors[j].name()
+	})
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.ReplaceAll(help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats label pairs as {a="1",b="2"}, adding any extra pairs at the end.
+func formatLabels(names, values []string, extra ...string) string {
+	pairs := []string{}
+
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i])))
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], labelEscaper.Replace(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// vec keeps one child metric per combination of label values.
+type vec[T any] struct {
+	lock     sync.Mutex
+	labels   []string
+	children map[string]T
+	values   map[string][]string
+	create   func() T
+}
+
+func newVec[T any](labels []string, create func() T) vec[T] {
+	return vec[T]{
+		labels:   labels,
+		children: make(map[string]T),
+		values:   make(map[string][]string),
+		create:   create,
+	}
+}
+
+func (v *vec[T]) with(values []string) T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	child, ok := v.children[key]
+
+	if !ok {
+		child = v.create()
+		v.children[key] = child
+		v.values[key] = append([]string(nil), values...)
+	}
+
+	return child
+}
+
+// each calls fn for every child, ordered by their label values.
+func (v *vec[T]) each(fn func(values []string, child T)) {
+	v.lock.Lock()
+	keys := make([]string, 0, len(v.children))
+
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+
+	v.lock.Unlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.lock.Lock()
+		child, values := v.children[key], v.values[key]
+		v.lock.Unlock()
+
+		fn(values, child)
+	}
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"flag"
+	"math"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Update the golden files")
+
+// withRegistry swaps in an empty default registry for the duration of a test.
+func withRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	previous := Default
+	Default = &Registry{}
+	t.Cleanup(func() { Default = previous })
+
+	return Default
+}
+
+// checkGolden compares the exposition of a registry with a file in testdata.
+func checkGolden(t *testing.T, r *Registry, name string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		if err := os.WriteFile(path, rec.Body.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rec.Body.String(); got != string(expected) {
+		t.Errorf("the exposition doesn't match %s:\n%s\nexpected:\n%s", path, got, expected)
+	}
+}
+
+func TestExposition(t *testing.T) {
+	r := withRegistry(t)
+
+	requests := NewCounterVec("test_requests_total", "The number of requests.", "route", "status")
+	requests.With("/b", "200").Add(2)
+	requests.With("/a", "200").Inc()
+	requests.With("/a", "500").Inc()
+
+	escaped := NewCounterVec("test_escaped_total", "A help text\nover two lines.", "value")
+	escaped.With("a \"quoted\" back\\slash\nand a newline").Inc()
+
+	NewCounterVec("test_unlabeled_total", "A counter without labels.").With().Add(0.5)
+
+	latency := NewHistogramVec("test_latency_seconds", "The latency of the lookups.", []float64{.1, 1}, "kind")
+	latency.With("ip").Observe(.05)
+	latency.With("ip").Observe(.5)
+	latency.With("ip").Observe(2)
+	latency.With("domain").Observe(.1)
+
+	NewGaugeFunc("test_cache_bytes", "The size of the caches.", []string{"cache"}, func(set func(float64, ...string)) {
+		set(1024, "ip")
+		set(0, "dns")
+	})
+
+	NewCounterFunc("test_cache_hits_total", "The cache hits.", nil, func(set func(float64, ...string)) {
+		set(1e6)
+	})
+
+	checkGolden(t, r, "exposition.golden")
+}
+
+func TestEmptyFamilies(t *testing.T) {
+	r := withRegistry(t)
+
+	NewCounterVec("test_empty_total", "A counter that was never used.", "route")
+	NewHistogramVec("test_empty_seconds", "A histogram that was never used.", DefaultBuckets)
+
+	checkGolden(t, r, "empty.golden")
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{0.25, "0.25"},
+		{1e21, "1e+21"},
+		{-3, "-3"},
+		{math.Inf(1), "+Inf"},
+	}
+
+	for _, test := range tests {
+		if got := formatValue(test.value); got != test.expected {
+			t.Errorf("formatValue(%v) = %q, expected %q", test.value, got, test.expected)
+		}
+	}
+}
+
+func TestWrongLabelCount(t *testing.T) {
+	withRegistry(t)
+	counter := NewCounterVec("test_labels_total", "A counter with two labels.", "a", "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for the wrong number of label values")
+		}
+	}()
+
+	counter.With("only one")
+}
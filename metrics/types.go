@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Counter is a value that only goes up.
+type Counter struct {
+	lock  sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(v float64) {
+	c.lock.Lock()
+	c.value += v
+	c.lock.Unlock()
+}
+
+func (c *Counter) get() float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.value
+}
+
+// CounterVec is a family of counters, one per combination of label values.
+type CounterVec struct {
+	vec[*Counter]
+	metricName string
+	help       string
+}
+
+// NewCounterVec creates a counter family and registers it with the default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		vec:        newVec(labels, func() *Counter { return &Counter{} }),
+		metricName: name,
+		help:       help,
+	}
+	Default.register(c)
+
+	return c
+}
+
+// With returns the counter for the given label values, in the order the labels were defined.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.with(values)
+}
+
+func (c *CounterVec) name() string {
+	return c.metricName
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	writeHeader(w, c.metricName, c.help, "counter")
+	c.each(func(values []string, counter *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, formatLabels(c.labels, values), formatValue(counter.get()))
+	})
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	lock    sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += v
+}
+
+// HistogramVec is a family of histograms, one per combination of label values.
+type HistogramVec struct {
+	vec[*Histogram]
+	metricName string
+	help       string
+	buckets    []float64
+}
+
+// NewHistogramVec creates a histogram family and registers it with the default registry.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		metricName: name,
+		help:       help,
+		buckets:    buckets,
+	}
+	h.vec = newVec(labels, func() *Histogram {
+		return &Histogram{
+			buckets: buckets,
+			counts:  make([]uint64, len(buckets)),
+		}
+	})
+	Default.register(h)
+
+	return h
+}
+
+// With returns the histogram for the given label values, in the order the labels were defined.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values)
+}
+
+func (h *HistogramVec) name() string {
+	return h.metricName
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	writeHeader(w, h.metricName, h.help, "histogram")
+	h.each(func(values []string, hist *Histogram) {
+		hist.lock.Lock()
+		defer hist.lock.Unlock()
+
+		for i, bound := range hist.buckets {
+			labels := formatLabels(h.labels, values, "le", formatValue(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labels, hist.counts[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, values, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, values), formatValue(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, values), hist.count)
+	})
+}
+
+// GaugeFunc is a gauge family whose values are collected from a callback on every scrape.
+type GaugeFunc struct {
+	kind       string
+	metricName string
+	help       string
+	labels     []string
+	collect    func(set func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc creates a gauge family and registers it with the default registry. The collect
+// function is called on every scrape and should call set once for each set of label values.
+func NewGaugeFunc(name, help string, labels []string, collect func(set func(value float64, labelValues ...string))) *GaugeFunc {
+	g := &GaugeFunc{
+		kind:       "gauge",
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		collect:    collect,
+	}
+	Default.register(g)
+
+	return g
+}
+
+// NewCounterFunc is like NewGaugeFunc, but for values that only go up and are counted elsewhere.
+func NewCounterFunc(name, help string, labels []string, collect func(set func(value float64, labelValues ...string))) *GaugeFunc {
+	g := NewGaugeFunc(name, help, labels, collect)
+	g.kind = "counter"
+
+	return g
+}
+
+func (g *GaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, g.kind)
+	g.collect(func(value float64, labelValues ...string) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, formatLabels(g.labels, labelValues), formatValue(value))
+	})
+}
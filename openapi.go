@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/cache"
+	"github.com/wisepythagoras/geoip-service/openapi"
+	"github.com/wisepythagoras/geoip-service/types"
+)
+
+// openAPIDocument is the encoded OpenAPI document, which is built once the routes are registered.
+var openAPIDocument []byte
+
+// route describes a built-in route for the OpenAPI document. Data is a value of the type that the
+// route returns in the data field of its response, if any.
+type route struct {
+	method      string
+	path        string
+	tag         string
+	summary     string
+	description string
+	params      []*openapi.Parameter
+	body        *openapi.RequestBody
+	data        any
+	errors      map[string]string
+}
+
+// The parameters that every lookup accepts, to pick the locale of the place names.
+var localeParams = []*openapi.Parameter{
+	{Name: "lang", In: "query", Description: "A comma separated list of the preferred locales of the place names (overrides Accept-Language)"},
+	{Name: "all_names", In: "query", Description: "Include the names in every locale", Schema: &openapi.Schema{Type: "boolean"}},
+	{Name: "Accept-Language", In: "header", Description: "The preferred locales of the place names"},
+}
+
+var hostnameParam = &openapi.Parameter{Name: "hostname", In: "path", Description: "The domain name to look up"}
+
+func withLocaleParams(params ...*openapi.Parameter) []*openapi.Parameter {
+	return append(params, localeParams...)
+}
+
+var builtinRoutes = []route{
+	{
+		method:  "GET",
+		path:    "/api/ip_address/info/:hostname",
+		tag:     "IP addresses",
+		summary: "Look up an IP address",
+		params: withLocaleParams(
+			&openapi.Parameter{Name: "hostname", In: "path", Description: "The IP address to look up"},
+			&openapi.Parameter{Name: "ptr", In: "query", Description: "Add the reverse DNS (PTR) records", Schema: &openapi.Schema{Type: "boolean"}},
+			&openapi.Parameter{Name: "fcrdns", In: "query", Description: "Forward-confirm the reverse DNS records (implies ptr)", Schema: &openapi.Schema{Type: "boolean"}},
+		),
+		data:   &types.IPRecord{},
+		errors: map[string]string{"500": "The IP address is invalid"},
+	},
+	{
+		method:      "POST",
+		path:        "/api/ip_address/batch",
+		tag:         "IP addresses",
+		summary:     "Look up several IP addresses",
+		description: "Every address gets its own result, so invalid addresses or failed lookups don't fail the whole batch.",
+		params:      withLocaleParams(),
+		body: &openapi.RequestBody{
+			Description: "The IP addresses to look up",
+			Required:    true,
+			Content:     openapi.JSON(&openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}}),
+		},
+		data: []*types.BatchIPResult{},
+		errors: map[string]string{
+			"400": "The body isn't a JSON array of strings",
+			"413": "There are more addresses than -max-batch allows",
+		},
+	},
+	{
+		method:      "GET",
+		path:        "/api/domain/fast_info/:hostname",
+		tag:         "Domains",
+		summary:     "Look up the addresses of a domain with the system resolver",
+		description: "Failed lookups are reported in the status of the response.",
+		params:      withLocaleParams(hostnameParam),
+		data:        []*types.IPRecord{},
+	},
+	{
+		method:      "GET",
+		path:        "/api/domain/info/:hostname",
+		tag:         "Domains",
+		summary:     "Look up the addresses of a domain with the DNS servers",
+		description: "Failed lookups are reported in the status of the response.",
+		params: withLocaleParams(
+			hostnameParam,
+			&openapi.Parameter{Name: "family", In: "query", Description: "The address family to resolve: 4, 6, or any", Schema: &openapi.Schema{Type: "string", Enum: []any{"4", "6", "any"}}},
+		),
+		data:   []*types.IPRecord{},
+		errors: map[string]string{"400": "The address family is invalid"},
+	},
+	{
+		method:      "GET",
+		path:        "/api/domain/records/:hostname",
+		tag:         "Domains",
+		summary:     "Look up the DNS records of a domain",
+		description: "The IP addresses the records point to are geolocated.",
+		params: withLocaleParams(
+			hostnameParam,
+			&openapi.Parameter{Name: "type", In: "query", Description: "A comma separated list of the record types, e.g. MX,NS,TXT (defaults to all)"},
+		),
+		data:   []*types.DNSRecord{},
+		errors: map[string]string{"400": "A record type is invalid"},
+	},
+	{
+		method:  "GET",
+		path:    "/api/dns_servers",
+		tag:     "Service",
+		summary: "List the DNS servers that domains are resolved with",
+		data:    []string{},
+	},
+	{
+		method:  "GET",
+		path:    "/api/databases",
+		tag:     "Service",
+		summary: "List the loaded databases and their metadata",
+		data:    []types.DatabaseInfo{},
+	},
+	{
+		method:  "GET",
+		path:    "/api/cache_stats",
+		tag:     "Service",
+		summary: "Get the counters of the IP and DNS caches",
+		data:    map[string]cache.Stats{},
+	},
+	{
+		method:  "POST",
+		path:    "/api/admin/reload_databases",
+		tag:     "Admin",
+		summary: "Reload the databases from disk",
+		errors:  map[string]string{"500": "The databases couldn't be reloaded, and the current ones are kept"},
+	},
+	{
+		method:  "GET",
+		path:    "/healthz",
+		tag:     "Service",
+		summary: "Check whether the service is alive",
+	},
+	{
+		method:  "GET",
+		path:    "/readyz",
+		tag:     "Service",
+		summary: "Check whether every component is ready to serve lookups",
+		data:    map[string]*types.ComponentStatus{},
+		errors:  map[string]string{"503": "A component isn't ready"},
+	},
+}
+
+// dataResponse returns the schema of an ApiResponse whose data is of the given type.
+func dataResponse(doc *openapi.Document, data any) *openapi.Schema {
+	schema := doc.SchemaOf(types.ApiResponse{})
+
+	if data == nil {
+		return schema
+	}
+
+	return &openapi.Schema{AllOf: []*openapi.Schema{
+		schema,
+		{Type: "object", Properties: map[string]*openapi.Schema{"data": doc.SchemaOf(data)}},
+	}}
+}
+
+// secureOperation sets the security requirements of an operation from the scope of its route.
+func secureOperation(method, route string, op *openapi.Operation) {
+	scope := routeScope(route)
+
+	if scope == "" {
+		return
+	}
+
+	note := fmt.Sprintf("Needs an API key with the `%s` scope.", scope)
+	op.Security = []openapi.SecurityRequirement{{"apiKey": {}}}
+
+	if isPublic(method, scope) {
+		note = fmt.Sprintf("Can be used without an API key, or with one that has the `%s` scope.", scope)
+		op.Security = append(op.Security, openapi.SecurityRequirement{})
+	}
+
+	op.Description = strings.TrimSpace(op.Description + " " + note)
+	op.Responses["401"] = &openapi.Response{Description: "The API key is missing or invalid"}
+	op.Responses["403"] = &openapi.Response{Description: "The API key doesn't have the scope, or the client isn't allowed to use the route"}
+}
+
+// buildOpenAPI describes the built-in routes and the endpoints of the extensions.
+func buildOpenAPI() ([]byte, error) {
+	doc := openapi.New(openapi.Info{
+		Title:       "GeoIP Service",
+		Description: "Geolocates IP addresses and domains.",
+		Version:     "1.0.0",
+	})
+	doc.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{
+		Type: "apiKey",
+		Name: "X-AUTH-TOKEN",
+		In:   "header",
+	}
+	doc.Tags = []openapi.Tag{{Name: "IP addresses"}, {Name: "Domains"}, {Name: "Service"}, {Name: "Admin"}}
+
+	// These are added first so that they keep their names, as extensions can refer to them.
+	doc.SchemaOf(types.IPRecord{})
+	doc.SchemaOf(types.ApiResponse{})
+
+	for _, r := range builtinRoutes {
+		op := &openapi.Operation{
+			Summary:     r.summary,
+			Description: r.description,
+			Tags:        []string{r.tag},
+			Parameters:  r.params,
+			RequestBody: r.body,
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "OK", Content: openapi.JSON(dataResponse(doc, r.data))},
+			},
+		}
+
+		for status, description := range r.errors {
+			op.Responses[status] = &openapi.Response{
+				Description: description,
+				Content:     openapi.JSON(dataResponse(doc, nil)),
+			}
+		}
+
+		secureOperation(r.method, r.path, op)
+		doc.AddOperation(r.method, r.path, op)
+	}
+
+	metricsOp := &openapi.Operation{
+		Summary: "Get the metrics in the Prometheus text format",
+		Tags:    []string{"Service"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "OK", Content: map[string]*openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}}},
+		},
+	}
+	secureOperation("GET", "/metrics", metricsOp)
+	doc.AddOperation("GET", "/metrics", metricsOp)
+
+	for _, ext := range extensions {
+		tag := "Extension: " + ext.Name()
+		doc.Tags = append(doc.Tags, openapi.Tag{Name: tag})
+
+		for _, details := range ext.Endpoints() {
+			// Only these methods are registered.
+			if !slices.Contains([]string{"GET", "POST", "PUT", "DELETE"}, details.Method) {
+				continue
+			}
+
+			// A null in the params would otherwise crash the server when the document is built.
+			if slices.Contains(details.Params, nil) {
+				return nil, fmt.Errorf("the extension %q has a null parameter in %s %s", ext.Name(), details.Method, details.Endpoint)
+			}
+
+			op := &openapi.Operation{
+				Summary:    details.Summary,
+				Tags:       []string{tag},
+				Parameters: details.Params,
+				Responses:  map[string]*openapi.Response{"200": {Description: "OK"}},
+			}
+
+			if details.Response != nil {
+				op.Responses["200"].Content = openapi.JSON(details.Response)
+			}
+
+			route := ext.Route(details)
+			secureOperation(details.Method, route, op)
+			doc.AddOperation(details.Method, route, op)
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// OpenAPIHandler serves the OpenAPI document of the API.
+func OpenAPIHandler(c *gin.Context) {
+	c.Data(200, "application/json", openAPIDocument)
+}
+
+// APIDocsHandler serves a page that renders the OpenAPI document.
+func APIDocsHandler(c *gin.Context) {
+	c.Header("Content-Security-Policy", openapi.UICSP)
+	c.Data(200, "text/html; charset=utf-8", openapi.UI)
+}
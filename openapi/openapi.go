@@ -0,0 +1,184 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Version is the version of the OpenAPI specification that the documents follow.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document. Only the parts of the specification that the service needs
+// are modeled.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	names      map[reflect.Type]string
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SecurityRequirement maps the name of a security scheme to the scopes it needs. An empty
+// requirement means that no credentials are needed.
+type SecurityRequirement map[string][]string
+
+// PathItem holds the operations of a path, keyed by their lowercase method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+// Parameter is a parameter of an operation, where In is path, query, or header.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is a JSON schema, in the dialect of OpenAPI 3.0. The zero value matches anything.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Example              any                `json:"example,omitempty"`
+}
+
+// Ref returns a schema that refers to one of the document's components.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// JSON returns the content of a JSON body with the given schema.
+func JSON(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// New creates an empty document.
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+	}
+}
+
+var routeParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// Path converts a gin route (e.g. /api/ip_address/info/:hostname) to an OpenAPI path (e.g.
+// /api/ip_address/info/{hostname}), and returns the names of its parameters.
+func Path(route string) (string, []string) {
+	names := []string{}
+
+	for _, match := range routeParam.FindAllStringSubmatch(route, -1) {
+		names = append(names, match[1])
+	}
+
+	return routeParam.ReplaceAllString(route, "{$1}"), names
+}
+
+// AddOperation adds the operation of a gin route to the document. Every parameter of the route
+// is declared, as OpenAPI requires, even if the operation leaves it out.
+func (d *Document) AddOperation(method, route string, op *Operation) {
+	path, names := Path(route)
+
+	for _, name := range names {
+		declared := false
+
+		for _, param := range op.Parameters {
+			if param.In == "path" && param.Name == name {
+				param.Required = true
+				declared = true
+			}
+		}
+
+		if !declared {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	for _, param := range op.Parameters {
+		if param.Schema == nil {
+			param.Schema = &Schema{Type: "string"}
+		}
+	}
+
+	if op.Responses == nil {
+		op.Responses = make(map[string]*Response)
+	}
+
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = &Response{Description: "OK"}
+	}
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = &PathItem{}
+	}
+
+	(*d.Paths[path])[strings.ToLower(method)] = op
+}
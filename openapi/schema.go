@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf returns the schema of a Go value's type, as it's encoded by encoding/json. Named
+// structs are added to the document's components and referred to, so that they're only described
+// once.
+func (d *Document) SchemaOf(v any) *Schema {
+	return d.schemaFor(reflect.TypeOf(v))
+}
+
+// typeNames returns the names that the struct types were added to the components under.
+func (d *Document) typeNames() map[reflect.Type]string {
+	if d.names == nil {
+		d.names = make(map[reflect.Type]string)
+	}
+
+	return d.names
+}
+
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := d.schemaFor(t.Elem())
+
+		// OpenAPI 3.0 ignores anything next to a $ref, so the reference needs to be wrapped.
+		if len(schema.Ref) > 0 {
+			return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+		}
+
+		schema.Nullable = true
+
+		return schema
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+
+		// Anonymous structs are described inline.
+		if len(t.Name()) == 0 {
+			return d.structSchema(t)
+		}
+
+		names := d.typeNames()
+
+		if name, found := names[t]; found {
+			return Ref(name)
+		}
+
+		name := d.componentName(t)
+		names[t] = name
+
+		// The name is taken before the fields are described, in case the type refers to itself.
+		d.Components.Schemas[name] = &Schema{}
+		d.Components.Schemas[name] = d.structSchema(t)
+
+		return Ref(name)
+	}
+
+	return &Schema{}
+}
+
+// componentName returns a name for a struct type that isn't taken by another type. Types with the
+// same name are told apart by their package.
+func (d *Document) componentName(t reflect.Type) string {
+	name := t.Name()
+
+	if _, taken := d.Components.Schemas[name]; !taken {
+		return name
+	}
+
+	pkg := t.PkgPath()
+
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+// structSchema describes the fields of a struct. The fields of embedded structs are promoted, as
+// they are by encoding/json.
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && len(name) == 0 {
+			embedded := field.Type
+
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				promoted := d.structSchema(embedded)
+
+				for key, property := range promoted.Properties {
+					schema.Properties[key] = property
+				}
+
+				schema.Required = append(schema.Required, promoted.Required...)
+
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		schema.Properties[name] = d.schemaFor(field.Type)
+
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
@@ -0,0 +1,13 @@
+package openapi
+
+import _ "embed"
+
+// UI is a self-contained page that lists the operations of the document and can call them, in
+// the style of Swagger UI. It expects the document to be served next to it, as openapi.json, and
+// loads nothing else, so it can be served with UICSP.
+//
+//go:embed ui.html
+var UI []byte
+
+// UICSP is the Content-Security-Policy of the UI, which only lets it call the API it's served by.
+const UICSP = "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
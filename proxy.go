@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trustedProxies are the proxies whose client IP headers and PROXY protocol headers are
+// believed. Requests from anywhere else are attributed to the address they came from.
+var trustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a comma separated list of IP addresses and CIDR ranges.
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+	ipRanges := []*net.IPNet{}
+
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); len(entry) == 0 {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, ipRange, err := net.ParseCIDR(entry)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ipRanges = append(ipRanges, ipRange)
+	}
+
+	return ipRanges, nil
+}
+
+// isTrustedProxy returns true if the IP address belongs to a trusted proxy.
+func isTrustedProxy(ip net.IP) bool {
+	for _, ipRange := range trustedProxies {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// configureClientIP makes gin (and so every handler and extension that asks for the client IP)
+// look for it in the given headers, in order, but only when the request came from a trusted
+// proxy.
+func configureClientIP(r *gin.Engine, headers []string) error {
+	cidrs := []string{}
+
+	for _, ipRange := range trustedProxies {
+		cidrs = append(cidrs, ipRange.String())
+	}
+
+	r.ForwardedByClientIP = len(headers) > 0
+	r.RemoteIPHeaders = headers
+
+	return r.SetTrustedProxies(cidrs)
+}
+
+// requestClientIP returns the IP address of the client that made the request.
+func requestClientIP(c *gin.Context) net.IP {
+	return net.ParseIP(c.ClientIP())
+}
@@ -0,0 +1,196 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// v2Signature starts every version 2 header.
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// maxV1Length is the longest a version 1 header can be, including the CRLF.
+const maxV1Length = 107
+
+// Listener reads the PROXY protocol header (version 1 or 2) of the connections that come from a
+// trusted proxy, and reports the address in the header as the connection's remote address.
+// Connections from anywhere else are left alone, so nobody else can claim a different address.
+type Listener struct {
+	net.Listener
+	// Trusted returns true if the connections from this address carry a PROXY header.
+	Trusted func(ip net.IP) bool
+	// Timeout is how long to wait for the header.
+	Timeout time.Duration
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+
+	if !ok || l.Trusted == nil || !l.Trusted(addr.IP) {
+		return conn, nil
+	}
+
+	// The header is read on the first use of the connection, so that a slow proxy doesn't hold up
+	// the accept loop.
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.Timeout}, nil
+}
+
+// Conn is a connection that starts with a PROXY header.
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	once    sync.Once
+	remote  net.Addr
+	err     error
+}
+
+func (c *Conn) readHeader() {
+	if c.timeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+	}
+
+	c.remote, c.err = ReadHeader(c.reader)
+
+	if c.err != nil {
+		c.Conn.Close()
+	}
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client's address from the header. If the header has no address (e.g.
+// the proxy's own health checks), it's the proxy's address.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+
+	if c.remote == nil {
+		return c.Conn.RemoteAddr()
+	}
+
+	return c.remote
+}
+
+// ReadHeader reads a version 1 or 2 header and returns the source address in it, which is nil
+// for the UNKNOWN (v1) and LOCAL (v2) headers.
+func ReadHeader(r *bufio.Reader) (net.Addr, error) {
+	start, err := r.Peek(len(v2Signature))
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the PROXY header: %w", err)
+	}
+
+	if bytes.Equal(start, v2Signature) {
+		return readV2(r)
+	}
+
+	if bytes.HasPrefix(start, []byte("PROXY ")) {
+		return readV1(r)
+	}
+
+	return nil, errors.New("the connection doesn't start with a PROXY header")
+}
+
+// readV1 reads a header such as "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	line := []byte{}
+
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= maxV1Length {
+			return nil, errors.New("the PROXY header is too long")
+		}
+
+		b, err := r.ReadByte()
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the PROXY header: %w", err)
+		}
+
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(string(line))
+
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY header %q", strings.TrimSpace(string(line)))
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid PROXY header %q", strings.TrimSpace(string(line)))
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readV2 reads a binary header: the signature, the version and command, the address family and
+// protocol, the length of the rest, and the addresses.
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("unable to read the PROXY header: %w", err)
+	}
+
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", header[12]>>4)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("unable to read the PROXY header: %w", err)
+	}
+
+	// The LOCAL command is used by the proxy for its own connections.
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+
+	switch header[13] >> 4 {
+	case 1:
+		if len(body) < 12 {
+			return nil, errors.New("the PROXY header is too short for an IPv4 address")
+		}
+
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 2:
+		if len(body) < 36 {
+			return nil, errors.New("the PROXY header is too short for an IPv6 address")
+		}
+
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	}
+
+	// Other families (e.g. Unix sockets) don't have an IP address to use.
+	return nil, nil
+}
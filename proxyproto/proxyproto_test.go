@@ -0,0 +1,172 @@
+package proxyproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// v2 builds a version 2 header with the given command, family, and addresses.
+func v2(command, family byte, body []byte) string {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(body)))
+
+	return string(append(header, body...))
+}
+
+// v2Addrs builds the addresses of a version 2 header.
+func v2Addrs(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	body := append(append([]byte{}, src...), dst...)
+	body = binary.BigEndian.AppendUint16(body, srcPort)
+
+	return binary.BigEndian.AppendUint16(body, dstPort)
+}
+
+func TestReadHeader(t *testing.T) {
+	ipv4 := net.ParseIP("192.0.2.1").To4()
+	ipv6 := net.ParseIP("2001:db8::1")
+
+	tests := []struct {
+		name   string
+		input  string
+		addr   string
+		failed bool
+	}{
+		{name: "v1 TCP4", input: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n", addr: "192.0.2.1:56324"},
+		{name: "v1 TCP6", input: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", addr: "[2001:db8::1]:56324"},
+		{name: "v1 UNKNOWN", input: "PROXY UNKNOWN\r\n"},
+		{name: "v1 UNKNOWN with addresses", input: "PROXY UNKNOWN 192.0.2.1 192.0.2.2 56324 443\r\n"},
+		{name: "v1 missing fields", input: "PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\n", failed: true},
+		{name: "v1 invalid protocol", input: "PROXY UDP4 192.0.2.1 192.0.2.2 56324 443\r\n", failed: true},
+		{name: "v1 invalid address", input: "PROXY TCP4 192.0.2.300 192.0.2.2 56324 443\r\n", failed: true},
+		{name: "v1 invalid port", input: "PROXY TCP4 192.0.2.1 192.0.2.2 65536 443\r\n", failed: true},
+		{name: "v1 too long", input: "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", failed: true},
+		{name: "v1 without CRLF", input: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443", failed: true},
+		{name: "v2 IPv4", input: v2(1, 0x11, v2Addrs(ipv4, ipv4, 56324, 443)), addr: "192.0.2.1:56324"},
+		{name: "v2 IPv6", input: v2(1, 0x21, v2Addrs(ipv6, ipv6, 56324, 443)), addr: "[2001:db8::1]:56324"},
+		{name: "v2 IPv4 with TLVs", input: v2(1, 0x11, append(v2Addrs(ipv4, ipv4, 80, 443), 0x04, 0x00, 0x01, 0x00)), addr: "192.0.2.1:80"},
+		{name: "v2 LOCAL", input: v2(0, 0x11, v2Addrs(ipv4, ipv4, 56324, 443))},
+		{name: "v2 Unix socket", input: v2(1, 0x31, make([]byte, 216))},
+		{name: "v2 short IPv4", input: v2(1, 0x11, make([]byte, 8)), failed: true},
+		{name: "v2 short IPv6", input: v2(1, 0x21, make([]byte, 12)), failed: true},
+		{name: "v2 truncated", input: v2(1, 0x11, v2Addrs(ipv4, ipv4, 56324, 443))[:20], failed: true},
+		{name: "v2 wrong version", input: string(v2Signature) + "\x11\x11\x00\x00", failed: true},
+		{name: "no header", input: "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n", failed: true},
+		{name: "too short", input: "PROXY", failed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The request that follows the header has to be left for the server.
+			r := bufio.NewReader(strings.NewReader(test.input + "GET /"))
+			addr, err := ReadHeader(r)
+
+			if test.failed {
+				if err == nil {
+					t.Fatalf("expected an error, got the address %v", addr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(test.addr) == 0 {
+				if addr != nil {
+					t.Errorf("expected no address, got %v", addr)
+				}
+			} else if addr == nil || addr.String() != test.addr {
+				t.Errorf("expected the address %s, got %v", test.addr, addr)
+			}
+
+			if rest, _ := io.ReadAll(r); string(rest) != "GET /" {
+				t.Errorf("expected the rest of the stream to be left, got %q", rest)
+			}
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted bool
+		header  string
+		remote  string
+	}{
+		{name: "trusted", trusted: true, header: "PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n", remote: "192.0.2.1:56324"},
+		{name: "trusted without an address", trusted: true, header: "PROXY UNKNOWN\r\n"},
+		{name: "untrusted", header: "PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l := &Listener{
+				Listener: inner,
+				Trusted:  func(ip net.IP) bool { return test.trusted },
+				Timeout:  time.Second,
+			}
+			defer l.Close()
+
+			client, err := net.Dial("tcp", inner.Addr().String())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer client.Close()
+
+			if _, err = io.WriteString(client, test.header+"hello"); err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := l.Accept()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer conn.Close()
+
+			// Without an address in the header, or from an untrusted client, it's the peer's.
+			remote := test.remote
+
+			if len(remote) == 0 {
+				remote = client.LocalAddr().String()
+			}
+
+			if conn.RemoteAddr().String() != remote {
+				t.Errorf("expected the remote address %s, got %s", remote, conn.RemoteAddr())
+			}
+
+			// An untrusted client's header is passed on as it is.
+			expected := "hello"
+
+			if !test.trusted {
+				expected = test.header + "hello"
+			}
+
+			data := make([]byte, len(expected))
+
+			if _, err = io.ReadFull(conn, data); err != nil {
+				t.Fatal(err)
+			}
+
+			if string(data) != expected {
+				t.Errorf("expected to read %q, got %q", expected, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wisepythagoras/geoip-service/auth"
+	"github.com/wisepythagoras/geoip-service/metrics"
+	"github.com/wisepythagoras/geoip-service/ratelimit"
+)
+
+// clientLimiters and keyLimiters are the rate limiters of each route group, for the requests
+// without and with an API key respectively.
+var clientLimiters = map[string]*ratelimit.Limiter{}
+var keyLimiters = map[string]*ratelimit.Limiter{}
+
+var rateLimitedCount = metrics.NewCounterVec(
+	"geoip_rate_limited_requests_total",
+	"The number of requests that were turned away by the rate limits, by route group.",
+	"group",
+)
+
+// parseRateLimits parses a list of group=limit definitions (e.g. domain=10/s:20) into a limiter
+// per route group.
+func parseRateLimits(defs []string) (map[string]*ratelimit.Limiter, error) {
+	limiters := make(map[string]*ratelimit.Limiter)
+
+	for _, def := range defs {
+		group, limitDef, found := strings.Cut(def, "=")
+
+		if !found {
+			return nil, fmt.Errorf("invalid rate limit %q (expected group=limit)", def)
+		}
+
+		if group != groupIP && group != groupDomain && group != groupExtension {
+			return nil, fmt.Errorf("unknown route group %q (expected ip, domain, or ext)", group)
+		}
+
+		limit, err := ratelimit.ParseLimit(limitDef)
+
+		if err != nil {
+			return nil, err
+		}
+
+		limiters[group] = ratelimit.New(limit)
+	}
+
+	return limiters, nil
+}
+
+// rateLimitMiddleware applies the rate limit of the route's group. Requests with an API key are
+// limited per key if the group has a key limit, and everything else is limited per client IP.
+func rateLimitMiddleware(c *gin.Context) {
+	group, bucketName := routeGroup(c.FullPath())
+
+	if len(group) == 0 {
+		c.Next()
+		return
+	}
+
+	limiter := clientLimiters[group]
+	bucketKey := bucketName + " ip " + requestClientIP(c).String()
+
+	if value, found := c.Get(apiKeyContextKey); found && keyLimiters[group] != nil {
+		limiter = keyLimiters[group]
+		bucketKey = bucketName + " key " + value.(*auth.Key).Name
+	}
+
+	if limiter == nil {
+		c.Next()
+		return
+	}
+
+	result := limiter.Allow(bucketKey)
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(result.Reset.Seconds())))
+
+	if !result.Allowed {
+		rateLimitedCount.With(group).Inc()
+		c.Header("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())))
+		c.AbortWithStatus(429)
+		return
+	}
+
+	c.Next()
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets that have filled up again are dropped.
+const sweepInterval = time.Minute
+
+var units = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// Limit is the rate at which a bucket refills and the number of requests it holds.
+type Limit struct {
+	// Rate is the number of requests per second.
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit written as count/unit[:burst], where the unit is s, m, or h (e.g.
+// 10/s, 600/m:50). The burst defaults to the count.
+func ParseLimit(def string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(def, ":")
+	count, unit, found := strings.Cut(rate, "/")
+
+	if !found {
+		return Limit{}, fmt.Errorf("invalid limit %q (expected count/unit[:burst])", def)
+	}
+
+	n, err := strconv.Atoi(count)
+
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid count in the limit %q", def)
+	}
+
+	per, found := units[unit]
+
+	if !found {
+		return Limit{}, fmt.Errorf("invalid unit in the limit %q (expected s, m, or h)", def)
+	}
+
+	limit := Limit{Rate: float64(n) / per.Seconds(), Burst: n}
+
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid burst in the limit %q", def)
+		}
+	}
+
+	return limit, nil
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait until a token is available, if none was.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter keeps a token bucket per key (e.g. a client IP or an API key).
+type Limiter struct {
+	limit     Limit
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New creates a limiter where every key gets a bucket with the given limit.
+func New(limit Limit) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// refill adds the tokens earned since the bucket was last updated.
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+elapsed*l.limit.Rate)
+	b.updated = now
+}
+
+// sweep drops the buckets that are full, since they are the same as new ones. It must be called
+// with the lock held.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+
+		if b.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+func (l *Limiter) secondsFor(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens/l.limit.Rate)) * time.Second
+}
+
+// Allow takes a token from the key's bucket, if there is one.
+func (l *Limiter) Allow(key string) Result {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	b, found := l.buckets[key]
+
+	if !found {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = b
+	} else {
+		l.refill(b, now)
+	}
+
+	result := Result{Limit: l.limit.Burst}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.secondsFor(1 - b.tokens)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = l.secondsFor(float64(l.limit.Burst) - b.tokens)
+
+	return result
+}
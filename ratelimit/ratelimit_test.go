@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		def      string
+		expected Limit
+		valid    bool
+	}{
+		{"10/s", Limit{Rate: 10, Burst: 10}, true},
+		{"600/m:50", Limit{Rate: 10, Burst: 50}, true},
+		{"3600/h", Limit{Rate: 1, Burst: 3600}, true},
+		{"1/m:1", Limit{Rate: 1.0 / 60, Burst: 1}, true},
+		{"10", Limit{}, false},
+		{"0/s", Limit{}, false},
+		{"-1/s", Limit{}, false},
+		{"x/s", Limit{}, false},
+		{"10/d", Limit{}, false},
+		{"10/s:0", Limit{}, false},
+		{"10/s:x", Limit{}, false},
+	}
+
+	for _, test := range tests {
+		limit, err := ParseLimit(test.def)
+
+		if !test.valid {
+			if err == nil {
+				t.Errorf("ParseLimit(%q) = %+v, expected an error", test.def, limit)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseLimit(%q) failed: %v", test.def, err)
+		} else if limit != test.expected {
+			t.Errorf("ParseLimit(%q) = %+v, expected %+v", test.def, limit, test.expected)
+		}
+	}
+}
+
+// rewind moves the last update of a key's bucket back, as if that much time had passed.
+func rewind(l *Limiter, key string, d time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.buckets[key].updated = l.buckets[key].updated.Add(-d)
+}
+
+func TestAllow(t *testing.T) {
+	tests := []struct {
+		name string
+		// elapsed is how long to wait before each request, and allowed whether it goes through.
+		elapsed   []time.Duration
+		allowed   []bool
+		remaining int
+	}{
+		{
+			name:      "burst",
+			elapsed:   []time.Duration{0, 0, 0, 0},
+			allowed:   []bool{true, true, true, false},
+			remaining: 0,
+		},
+		{
+			name:      "partial refill",
+			elapsed:   []time.Duration{0, 0, 0, 500 * time.Millisecond},
+			allowed:   []bool{true, true, true, true},
+			remaining: 0,
+		},
+		{
+			name:      "refill after the burst",
+			elapsed:   []time.Duration{0, 0, 0, 0, time.Second},
+			allowed:   []bool{true, true, true, false, true},
+			remaining: 1,
+		},
+		{
+			name:      "refill is capped at the burst",
+			elapsed:   []time.Duration{0, time.Hour},
+			allowed:   []bool{true, true},
+			remaining: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// 2 tokens per second, and up to 3 at once.
+			l := New(Limit{Rate: 2, Burst: 3})
+			var result Result
+
+			for i, elapsed := range test.elapsed {
+				if i > 0 {
+					rewind(l, "client", elapsed)
+				}
+
+				result = l.Allow("client")
+
+				if result.Allowed != test.allowed[i] {
+					t.Fatalf("request %d: allowed = %v, expected %v", i, result.Allowed, test.allowed[i])
+				}
+			}
+
+			if result.Remaining != test.remaining {
+				t.Errorf("remaining = %d, expected %d", result.Remaining, test.remaining)
+			}
+
+			if result.Limit != 3 {
+				t.Errorf("limit = %d, expected 3", result.Limit)
+			}
+		})
+	}
+}
+
+func TestAllowRetryAfter(t *testing.T) {
+	l := New(Limit{Rate: 1.0 / 60, Burst: 1})
+
+	if result := l.Allow("client"); !result.Allowed || result.Reset != time.Minute {
+		t.Fatalf("unexpected first result %+v", result)
+	}
+
+	result := l.Allow("client")
+
+	if result.Allowed || result.RetryAfter != time.Minute || result.Reset != time.Minute {
+		t.Errorf("unexpected result %+v for an empty bucket", result)
+	}
+}
+
+func TestAllowSeparateKeys(t *testing.T) {
+	l := New(Limit{Rate: 1, Burst: 1})
+
+	if !l.Allow("a").Allowed || l.Allow("a").Allowed {
+		t.Fatal("expected a to get exactly one request through")
+	}
+
+	if !l.Allow("b").Allowed {
+		t.Error("expected b to have its own bucket")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l := New(Limit{Rate: 1, Burst: 2})
+	l.Allow("full")
+	l.Allow("empty")
+	l.Allow("empty")
+
+	rewind(l, "full", time.Second)
+	l.lock.Lock()
+	l.sweep(time.Now())
+	_, fullKept := l.buckets["full"]
+	_, emptyKept := l.buckets["empty"]
+	l.lock.Unlock()
+
+	if fullKept {
+		t.Error("expected the bucket that filled up again to be dropped")
+	}
+
+	if !emptyKept {
+		t.Error("expected the bucket that isn't full to be kept")
+	}
+}
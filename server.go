@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-co-op/gocron"
+)
+
+// updateScheduler runs the scheduled database updates, if -update-cron was set.
+var updateScheduler *gocron.Scheduler
+
+// listen opens the listener for an address, which is either host:port or unix:/path/to.sock.
+// A socket left behind by a previous run is removed first, but anything else at the path is left
+// alone and returned as an error.
+func listen(address string) (net.Listener, error) {
+	if path, isUnix := strings.CutPrefix(address, "unix:"); isUnix {
+		if info, err := os.Lstat(path); err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return nil, fmt.Errorf("%q already exists and isn't a socket", path)
+			}
+
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+
+		ln, err := net.Listen("unix", path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &unixListener{ln}, nil
+	}
+
+	return net.Listen("tcp", address)
+}
+
+// unixListener reports its connections as coming from the loopback address, since they can only
+// come from the same host. Otherwise they would have no client IP at all.
+type unixListener struct {
+	net.Listener
+}
+
+func (l *unixListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &unixConn{conn}, nil
+}
+
+type unixConn struct {
+	net.Conn
+}
+
+func (c *unixConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+// runServer serves until the process receives a SIGINT or SIGTERM, and then shuts down gracefully:
+// the in-flight requests are drained (for up to shutdownTimeout), the cron jobs are stopped, and
+// the extensions' and the lookup databases are closed.
+func runServer(srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+
+	go func() {
+		// The certificate comes from the TLS config, so no files are passed here.
+		if srv.TLSConfig != nil {
+			errs <- srv.ServeTLS(ln, "", "")
+		} else {
+			errs <- srv.Serve(ln)
+		}
+	}()
+
+	fmt.Println("Listening on", ln.Addr())
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Unable to drain the requests:", err)
+	}
+
+	if updateScheduler != nil {
+		updateScheduler.Stop()
+	}
+
+	for _, ext := range extensions {
+		if err := ext.Close(); err != nil {
+			log.Printf("Unable to close extension %q: %s\n", ext.Name(), err)
+		}
+	}
+
+	database.Close()
+	keyring.Close()
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables that override the settings. The rest of
+// the name is the flag's name in upper case, with dashes replaced by underscores (e.g. the
+// -ip-cache-mem flag can be set with GEOIP_IP_CACHE_MEM).
+const EnvPrefix = "GEOIP_"
+
+// redactedFlags are the flags whose values are hidden by -print-config.
+var redactedFlags = map[string]bool{
+	"api-key": true,
+}
+
+// listFlag is a flag that can be repeated, collecting every value. In the config file it's
+// written as a list, and in the environment the values are separated by semicolons or newlines,
+// since some values have commas in them (e.g. deny:country=CN,RU).
+type listFlag struct {
+	values []string
+}
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+
+	return strings.Join(l.values, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	l.values = append(l.values, value)
+	return nil
+}
+
+func (l *listFlag) Get() any {
+	return l.values
+}
+
+// envName returns the environment variable that overrides a flag.
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// ReadSettingsFile reads a YAML, TOML, or JSON config file (picked by its extension). The keys
+// are the names of the command line flags.
+func ReadSettingsFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]any)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &settings)
+	case ".toml":
+		err = toml.Unmarshal(data, &settings)
+	case ".json":
+		err = json.Unmarshal(data, &settings)
+	default:
+		return nil, fmt.Errorf("unsupported config file %q (expected .yaml, .toml, or .json)", path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// readConfig reads the config file at path, or at GEOIP_CONFIG if path is empty. It returns no
+// settings if neither is set. The config file is read before the other settings are applied, so
+// its variable is checked here.
+func readConfig(path string) (map[string]any, error) {
+	if len(path) == 0 {
+		path = os.Getenv(envName("config"))
+	}
+
+	if len(path) == 0 {
+		return make(map[string]any), nil
+	}
+
+	settings, err := ReadSettingsFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the config file: %w", err)
+	}
+
+	return settings, nil
+}
+
+// applyCommandSettings fills in the flags of a subcommand like ApplySettings does for the server.
+// The config file is shared with the server, so the settings the subcommand doesn't have are
+// skipped. The subcommand's own flags (e.g. -name) are only read from the command line, since
+// they could clash with server settings of the same name.
+func applyCommandSettings(fs *flag.FlagSet, configPath string, own ...string) error {
+	fileSettings, err := readConfig(configPath)
+
+	if err != nil {
+		return err
+	}
+
+	for name := range fileSettings {
+		if fs.Lookup(name) == nil || slices.Contains(own, name) {
+			delete(fileSettings, name)
+		}
+	}
+
+	return ApplySettings(fs, fileSettings, append(own, "config")...)
+}
+
+// settingToStrings converts a value from the config file to the flag values it stands for. Lists
+// are only allowed for repeatable flags.
+func settingToStrings(name string, value any, repeatable bool) ([]string, error) {
+	if list, ok := value.([]any); ok {
+		if !repeatable {
+			return nil, fmt.Errorf("the %q setting can't be a list", name)
+		}
+
+		values := []string{}
+
+		for _, item := range list {
+			v, err := settingToStrings(name, item, false)
+
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, v...)
+		}
+
+		return values, nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case bool, int, int64, uint64:
+		return []string{fmt.Sprint(v)}, nil
+	}
+
+	return nil, fmt.Errorf("the %q setting has an unsupported value", name)
+}
+
+// ApplySettings fills in the flags that weren't set on the command line, first from the
+// environment and then from the config file. So flags take precedence over environment variables,
+// which take precedence over the config file, which takes precedence over the defaults.
+func ApplySettings(fs *flag.FlagSet, fileSettings map[string]any, ignored ...string) error {
+	setOnCommandLine := make(map[string]bool)
+
+	fs.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	for name := range fileSettings {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q in the config file", name)
+		}
+	}
+
+	var err error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || setOnCommandLine[f.Name] {
+			return
+		}
+
+		for _, name := range ignored {
+			if f.Name == name {
+				return
+			}
+		}
+
+		_, repeatable := f.Value.(*listFlag)
+		var values []string
+
+		if env, found := os.LookupEnv(envName(f.Name)); found {
+			values = []string{env}
+
+			if repeatable {
+				values = strings.FieldsFunc(env, func(r rune) bool {
+					return r == ';' || r == '\n'
+				})
+			}
+		} else if setting, found := fileSettings[f.Name]; found {
+			values, err = settingToStrings(f.Name, setting, repeatable)
+
+			if err != nil {
+				return
+			}
+		}
+
+		for _, value := range values {
+			if err = fs.Set(f.Name, strings.TrimSpace(value)); err != nil {
+				err = fmt.Errorf("invalid value for the %q setting: %w", f.Name, err)
+				return
+			}
+		}
+	})
+
+	return err
+}
+
+// PrintSettings writes the effective settings as YAML, in the same format as the config file.
+// Secrets are redacted.
+func PrintSettings(fs *flag.FlagSet, ignored ...string) error {
+	settings := make(map[string]any)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		for _, name := range ignored {
+			if f.Name == name {
+				return
+			}
+		}
+
+		var value any = f.Value.String()
+
+		if getter, ok := f.Value.(flag.Getter); ok {
+			value = getter.Get()
+		}
+
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+
+		if redactedFlags[f.Name] && f.Value.String() != "" {
+			value = "<redacted>"
+		}
+
+		settings[f.Name] = value
+	})
+
+	// The keys of maps are sorted when marshalled, so the output is stable.
+	out, err := yaml.Marshal(settings)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(out))
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// privilegedSubjects are the client certificate subjects that are granted the same privileges as
+// the API key. An entry either matches the whole subject (e.g. "CN=ops,O=Example") or only its
+// common name.
+var privilegedSubjects = []string{}
+
+// certReloader serves the certificate from the given files, and loads it again when they change.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// lastModified returns the latest modification time of the certificate and key files.
+func (r *certReloader) lastModified() time.Time {
+	var latest time.Time
+
+	for _, path := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest
+}
+
+// reload loads the key pair again. The current certificate is kept if the new one is invalid.
+func (r *certReloader) reload() error {
+	modTime := r.lastModified()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.cert = &cert
+	r.modTime = modTime
+
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.cert, nil
+}
+
+// watch reloads the certificate on a SIGHUP and, if interval is set, whenever the files change.
+func (r *certReloader) watch(interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var ticks <-chan time.Time
+
+	if interval > 0 {
+		ticks = time.NewTicker(interval).C
+	}
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+			case <-ticks:
+				r.lock.RLock()
+				changed := !r.lastModified().Equal(r.modTime)
+				r.lock.RUnlock()
+
+				if !changed {
+					continue
+				}
+			}
+
+			if err := r.reload(); err != nil {
+				log.Println("Unable to reload the TLS certificate:", err)
+			} else {
+				log.Println("Reloaded the TLS certificate")
+			}
+		}
+	}()
+}
+
+// newTLSConfig creates the server's TLS configuration. If clientCAFile is set, client certificates
+// signed by those CAs are verified (and required, if requireClientCert is set).
+func newTLSConfig(reloader *certReloader, minVersion, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	version, ok := tlsVersions[minVersion]
+
+	if !ok {
+		return nil, fmt.Errorf("invalid minimum TLS version %q (expected 1.0, 1.1, 1.2, or 1.3)", minVersion)
+	}
+
+	config := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     version,
+	}
+
+	if len(clientCAFile) == 0 {
+		if requireClientCert {
+			return nil, errors.New("client certificates can only be required if a client CA is specified")
+		}
+
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates were found in %q", clientCAFile)
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+
+	if requireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// hasPrivilegedClientCert returns true if the request came with a verified client certificate
+// whose subject was granted the API key's privileges.
+func hasPrivilegedClientCert(c *gin.Context) bool {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return false
+	}
+
+	subject := c.Request.TLS.VerifiedChains[0][0].Subject
+
+	for _, entry := range privilegedSubjects {
+		if entry == subject.String() || entry == subject.CommonName {
+			return true
+		}
+	}
+
+	return false
+}
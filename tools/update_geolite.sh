@@ -4,6 +4,12 @@ tar -xvf GeoLite2-City_$(date +%Y)*.tar.gz
 tar -xvf GeoLite2-Country_$(date +%Y)*.tar.gz
 tar -xvf GeoLite2-ASN_$(date +%Y)*.tar.gz
 
-cp GeoLite2-*_$(date +%Y)*/*.mmdb geolite/
+# Copy to a temporary file and rename it, so that a running server never maps a half-written
+# database. The server picks up the new files on reload.
+for db in GeoLite2-*_$(date +%Y)*/*.mmdb; do
+    name=$(basename "$db")
+    cp "$db" "geolite/$name.tmp"
+    mv "geolite/$name.tmp" "geolite/$name"
+done
 
 rm -rf GeoLite2-*
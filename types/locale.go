@@ -0,0 +1,70 @@
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is the locale that every fallback chain ends with.
+const DefaultLocale = "en"
+
+// pickName returns the name in the best matching locale. Each requested locale is tried as it
+// is first and then by its base language (e.g. "pt" matches "pt-BR"), before falling back to the
+// default locale. The keys are tried in sorted order, so that the same request always gets the
+// same name (e.g. "en-GB" rather than "en-US" for "en").
+func pickName(names map[string]string, locales []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(names))
+
+	for key := range names {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, locale := range append(locales, DefaultLocale) {
+		for _, key := range keys {
+			if strings.EqualFold(key, locale) {
+				return names[key]
+			}
+		}
+
+		base, _, _ := strings.Cut(locale, "-")
+
+		for _, key := range keys {
+			keyBase, _, _ := strings.Cut(key, "-")
+
+			if strings.EqualFold(keyBase, base) {
+				return names[key]
+			}
+		}
+	}
+
+	return ""
+}
+
+// Localize sets the name for the requested locales, in order of preference. The translations
+// are dropped unless keepAll is set.
+func (n *Names) Localize(locales []string, keepAll bool) {
+	n.Name = pickName(n.AllNames, locales)
+
+	if !keepAll {
+		n.AllNames = nil
+	}
+}
+
+// Localize sets the names of every place in the record for the requested locales.
+func (rec *IPRecord) Localize(locales []string, keepAll bool) {
+	rec.Continent.Localize(locales, keepAll)
+	rec.Country.Localize(locales, keepAll)
+	rec.RegisteredCountry.Localize(locales, keepAll)
+	rec.RepresentedCountry.Localize(locales, keepAll)
+	rec.City.Localize(locales, keepAll)
+
+	for i := range rec.Subdivisions {
+		rec.Subdivisions[i].Localize(locales, keepAll)
+	}
+}
@@ -1,24 +1,121 @@
 package types
 
+import "time"
+
+// Names holds the localized names of a place. Name is the one picked for the requested locale,
+// while AllNames keeps every translation from the database and is only returned on request.
+type Names struct {
+	Name     string            `maxminddb:"-" json:"name"`
+	AllNames map[string]string `maxminddb:"names" json:"names,omitempty"`
+}
+
+type Country struct {
+	ISOCode           string `maxminddb:"iso_code" json:"iso_code"`
+	GeonameID         int    `maxminddb:"geoname_id" json:"geoname_id"`
+	IsInEuropeanUnion bool   `maxminddb:"is_in_european_union" json:"is_in_european_union"`
+	Names
+}
+
+// RepresentedCountry is the country represented by the users of the IP address, e.g. for a
+// military base. Type holds the kind of entity (e.g. "military").
+type RepresentedCountry struct {
+	Country
+	Type string `maxminddb:"type" json:"type,omitempty"`
+}
+
+type Subdivision struct {
+	ISOCode   string `maxminddb:"iso_code" json:"iso_code"`
+	GeonameID int    `maxminddb:"geoname_id" json:"geoname_id"`
+	Names
+}
+
 type IPRecord struct {
-	Country struct {
-		ISOCode   string            `maxminddb:"iso_code" json:"iso_code"`
-		GeonameID int               `maxminddb:"geoname_id" json:"geoname_id"`
-		Name      map[string]string `maxminddb:"names" json:"name"`
-	} `maxminddb:"country" json:"country"`
-	City struct {
-		GeonameID int               `maxminddb:"geoname_id" json:"geoname_id"`
-		Name      map[string]string `maxminddb:"names" json:"name"`
+	Continent struct {
+		Code      string `maxminddb:"code" json:"code"`
+		GeonameID int    `maxminddb:"geoname_id" json:"geoname_id"`
+		Names
+	} `maxminddb:"continent" json:"continent"`
+	Country            Country            `maxminddb:"country" json:"country"`
+	RegisteredCountry  Country            `maxminddb:"registered_country" json:"registered_country"`
+	RepresentedCountry RepresentedCountry `maxminddb:"represented_country" json:"represented_country"`
+	// Subdivisions are ordered from the largest to the smallest (e.g. state, then county).
+	Subdivisions []Subdivision `maxminddb:"subdivisions" json:"subdivisions"`
+	City         struct {
+		GeonameID int `maxminddb:"geoname_id" json:"geoname_id"`
+		Names
 	} `maxminddb:"city" json:"city"`
+	Postal struct {
+		Code string `maxminddb:"code" json:"code"`
+	} `maxminddb:"postal" json:"postal"`
 	Location struct {
-		Latitude  float32 `maxminddb:"latitude" json:"latitude"`
-		Longitude float32 `maxminddb:"longitude" json:"longitude"`
-		MetroCode int     `maxminddb:"metro_code" json:"metro_code"`
+		Latitude       float32 `maxminddb:"latitude" json:"latitude"`
+		Longitude      float32 `maxminddb:"longitude" json:"longitude"`
+		MetroCode      int     `maxminddb:"metro_code" json:"metro_code"`
+		AccuracyRadius int     `maxminddb:"accuracy_radius" json:"accuracy_radius"`
+		TimeZone       string  `maxminddb:"time_zone" json:"time_zone"`
 	} `maxminddb:"location" json:"location"`
-	ASN       int    `maxminddb:"autonomous_system_number" json:"asn"`
-	Org       string `maxminddb:"autonomous_system_organization" json:"org"`
-	IPAddress string `json:"ip_address"`
-	AddlData  []any  `json:"additional_data"`
+	Traits struct {
+		IsAnycast           bool `maxminddb:"is_anycast" json:"is_anycast"`
+		IsAnonymousProxy    bool `maxminddb:"is_anonymous_proxy" json:"is_anonymous_proxy"`
+		IsSatelliteProvider bool `maxminddb:"is_satellite_provider" json:"is_satellite_provider"`
+	} `maxminddb:"traits" json:"traits"`
+	ASN int    `maxminddb:"autonomous_system_number" json:"asn"`
+	Org string `maxminddb:"autonomous_system_organization" json:"org"`
+	// These are only set if the ISP, Anonymous-IP, Connection-Type, or Domain databases are used.
+	ISP                string `maxminddb:"isp" json:"isp,omitempty"`
+	Organization       string `maxminddb:"organization" json:"organization,omitempty"`
+	MobileCountryCode  string `maxminddb:"mobile_country_code" json:"mobile_country_code,omitempty"`
+	MobileNetworkCode  string `maxminddb:"mobile_network_code" json:"mobile_network_code,omitempty"`
+	IsAnonymous        bool   `maxminddb:"is_anonymous" json:"is_anonymous,omitempty"`
+	IsAnonymousVPN     bool   `maxminddb:"is_anonymous_vpn" json:"is_anonymous_vpn,omitempty"`
+	IsHostingProvider  bool   `maxminddb:"is_hosting_provider" json:"is_hosting_provider,omitempty"`
+	IsPublicProxy      bool   `maxminddb:"is_public_proxy" json:"is_public_proxy,omitempty"`
+	IsResidentialProxy bool   `maxminddb:"is_residential_proxy" json:"is_residential_proxy,omitempty"`
+	IsTorExitNode      bool   `maxminddb:"is_tor_exit_node" json:"is_tor_exit_node,omitempty"`
+	ConnectionType     string `maxminddb:"connection_type" json:"connection_type,omitempty"`
+	Domain             string `maxminddb:"domain" json:"domain,omitempty"`
+	// Custom holds the data from custom databases, keyed by the database's name.
+	Custom map[string]any `json:"custom,omitempty"`
+	// Databases holds the network each database matched, keyed by the database's name, which
+	// clients can use to cache the result for the whole network.
+	Databases  map[string]DatabaseMatch `json:"databases"`
+	IPAddress  string                   `json:"ip_address"`
+	RecordType string                   `json:"record_type,omitempty"`
+	PTR        []PTRRecord              `json:"ptr,omitempty"`
+	AddlData   []any                    `json:"additional_data"`
+}
+
+// DatabaseMatch is the network that a lookup matched in a database, along with the database's
+// type and build time.
+type DatabaseMatch struct {
+	Network      string `json:"network"`
+	Found        bool   `json:"found"`
+	DatabaseType string `json:"database_type"`
+	BuildEpoch   uint   `json:"build_epoch"`
+}
+
+// DatabaseInfo describes a loaded database and its metadata.
+type DatabaseInfo struct {
+	Name                     string            `json:"name"`
+	Kind                     string            `json:"kind"`
+	DatabaseType             string            `json:"database_type"`
+	Description              map[string]string `json:"description"`
+	Languages                []string          `json:"languages"`
+	BuildEpoch               uint              `json:"build_epoch"`
+	BuiltAt                  time.Time         `json:"built_at"`
+	IPVersion                uint              `json:"ip_version"`
+	NodeCount                uint              `json:"node_count"`
+	RecordSize               uint              `json:"record_size"`
+	BinaryFormatMajorVersion uint              `json:"binary_format_major_version"`
+	BinaryFormatMinorVersion uint              `json:"binary_format_minor_version"`
+}
+
+// PTRRecord is a host name found through a reverse DNS lookup. ForwardConfirmed is only set when
+// forward-confirmed reverse DNS (FCrDNS) was requested, and is true if the host name resolves
+// back to the queried address.
+type PTRRecord struct {
+	Hostname         string `json:"hostname"`
+	ForwardConfirmed *bool  `json:"forward_confirmed,omitempty"`
 }
 
 type ApiResponse struct {
@@ -27,7 +124,69 @@ type ApiResponse struct {
 	Data    any    `json:"data"`
 }
 
+// BatchIPResult holds the outcome of a single address in a batch lookup. Failures are reported
+// per item so that one bad address doesn't fail the whole batch.
+type BatchIPResult struct {
+	IPAddress string    `json:"ip_address"`
+	Success   bool      `json:"success"`
+	Status    string    `json:"status"`
+	Data      *IPRecord `json:"data"`
+}
+
+// ComponentStatus is the readiness of a single component of the service.
+type ComponentStatus struct {
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
 type DNSApiResponse struct {
 	Success bool     `json:"success"`
 	Servers []string `json:"servers"`
 }
+
+type MXRecord struct {
+	Preference uint16 `json:"preference"`
+	Exchange   string `json:"exchange"`
+}
+
+type SOARecord struct {
+	NS      string `json:"ns"`
+	Mbox    string `json:"mbox"`
+	Serial  uint32 `json:"serial"`
+	Refresh uint32 `json:"refresh"`
+	Retry   uint32 `json:"retry"`
+	Expire  uint32 `json:"expire"`
+	MinTTL  uint32 `json:"min_ttl"`
+}
+
+type CAARecord struct {
+	Flag  uint8  `json:"flag"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// DNSRecord is a single resource record returned by the record explorer. Value always holds the
+// textual form of the record's data, while the typed fields are only set for the matching type.
+// Addresses holds the geolocated IPs the record points to (directly or through a host name).
+type DNSRecord struct {
+	Name      string      `json:"name"`
+	Type      string      `json:"type"`
+	TTL       uint32      `json:"ttl"`
+	Value     string      `json:"value"`
+	Target    string      `json:"target,omitempty"`
+	Text      []string    `json:"text,omitempty"`
+	MX        *MXRecord   `json:"mx,omitempty"`
+	SOA       *SOARecord  `json:"soa,omitempty"`
+	CAA       *CAARecord  `json:"caa,omitempty"`
+	Addresses []*IPRecord `json:"addresses,omitempty"`
+}
+
+// Clone returns a copy of the record that can be changed (e.g. localized) without affecting the
+// original. Only the fields that are changed after a lookup are copied deeply.
+func (rec *IPRecord) Clone() *IPRecord {
+	clone := *rec
+	clone.Subdivisions = append([]Subdivision(nil), rec.Subdivisions...)
+	clone.PTR = append([]PTRRecord(nil), rec.PTR...)
+
+	return &clone
+}
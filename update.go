@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wisepythagoras/geoip-service/updater"
+)
+
+// addUpdaterFlags registers the flags that configure the database updater and returns a function
+// that builds the updater from them once they are parsed.
+func addUpdaterFlags(fs *flag.FlagSet) func() *updater.Updater {
+	baseURL := fs.String("update-url", updater.DefaultBaseURL, "The base URL to download the databases from (supports {edition} and {suffix} placeholders for mirrors)")
+	editions := fs.String("update-editions", strings.Join(updater.DefaultEditions, ","), "A comma separated list of the editions to download")
+	dir := fs.String("update-dir", "geolite", "The folder to install the downloaded databases in")
+	keep := fs.Int("update-keep", 3, "The number of previous versions of each database to keep for rollbacks")
+
+	return func() *updater.Updater {
+		return &updater.Updater{
+			BaseURL:    *baseURL,
+			LicenseKey: os.Getenv("GEOLITE_LICENSE_KEY"),
+			Editions:   strings.Split(*editions, ","),
+			Dir:        *dir,
+			Keep:       *keep,
+		}
+	}
+}
+
+// runUpdateDB is the entry point of the update-db command, which downloads the latest databases
+// or rolls them back to the previous version.
+func runUpdateDB(args []string) {
+	fs := flag.NewFlagSet("update-db", flag.ExitOnError)
+	newUpdater := addUpdaterFlags(fs)
+	rollback := fs.Bool("rollback", false, "Reinstall the previous version of each edition instead of downloading")
+	openAuditLog := addAuditFlags(fs)
+	configPath := fs.String("config", "", "The config file of the server, to read the shared settings (e.g. -update-dir) from")
+
+	fs.Parse(args)
+
+	if err := applyCommandSettings(fs, *configPath, "rollback"); err != nil {
+		fmt.Println("Config error:", err)
+		os.Exit(1)
+	}
+
+	if err := openAuditLog(); err != nil {
+		fmt.Println("Unable to open the audit log:", err)
+		os.Exit(1)
+	}
+
+	u := newUpdater()
+
+	if *rollback {
+		for _, edition := range u.Editions {
+			err := u.Rollback(edition)
+			audit("database rolled back", err, "trigger", "command", "edition", edition)
+
+			if err != nil {
+				fmt.Println("Rollback error:", err)
+				os.Exit(1)
+			}
+		}
+
+		return
+	}
+
+	err := u.Update()
+	audit("databases updated", err, "trigger", "command", "editions", u.Editions)
+
+	if err != nil {
+		fmt.Println("Update error:", err)
+		os.Exit(1)
+	}
+}
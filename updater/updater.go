@@ -0,0 +1,459 @@
+package updater
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// DefaultBaseURL is MaxMind's download endpoint.
+const DefaultBaseURL = "https://download.maxmind.com/app/geoip_download"
+
+// DefaultEditions are the editions that the service uses.
+var DefaultEditions = []string{"GeoLite2-ASN", "GeoLite2-City", "GeoLite2-Country"}
+
+type Updater struct {
+	// BaseURL is where the editions are downloaded from. By default it's queried the way
+	// MaxMind's endpoint is (?edition_id=...&license_key=...&suffix=...), but if it contains
+	// {edition} and {suffix} placeholders they are filled in instead, which works for plain
+	// file mirrors (e.g. https://mirror.local/{edition}.{suffix}).
+	BaseURL    string
+	LicenseKey string
+	Editions   []string
+	// Dir is the folder the databases are installed in.
+	Dir string
+	// Keep is the number of previous versions of each edition to keep for rollbacks.
+	Keep   int
+	Client *http.Client
+}
+
+// editionURL builds the download URL of an edition's file with the given suffix (tar.gz or
+// tar.gz.sha256).
+func (u *Updater) editionURL(edition, suffix string) string {
+	if strings.Contains(u.BaseURL, "{edition}") {
+		r := strings.NewReplacer("{edition}", edition, "{suffix}", suffix)
+		return r.Replace(u.BaseURL)
+	}
+
+	query := url.Values{}
+	query.Set("edition_id", edition)
+	query.Set("suffix", suffix)
+
+	if len(u.LicenseKey) > 0 {
+		query.Set("license_key", u.LicenseKey)
+	}
+
+	return fmt.Sprintf("%s?%s", u.BaseURL, query.Encode())
+}
+
+func (u *Updater) get(url string) (*http.Response, error) {
+	client := u.Client
+
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Minute}
+	}
+
+	resp, err := client.Get(url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %q from the download server", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// fetchChecksum downloads the SHA256 sidecar of an edition. The sidecar has the same format as
+// the output of sha256sum, so only the first field is used.
+func (u *Updater) fetchChecksum(edition string) (string, error) {
+	resp, err := u.get(u.editionURL(edition, "tar.gz.sha256"))
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(string(body))
+
+	if len(fields) == 0 {
+		return "", fmt.Errorf("the checksum of %s is empty", edition)
+	}
+
+	return strings.ToLower(fields[0]), nil
+}
+
+// download saves the tarball of an edition to a temporary file and verifies its checksum.
+func (u *Updater) download(edition string) (string, error) {
+	checksum, err := u.fetchChecksum(edition)
+
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := u.get(u.editionURL(edition, "tar.gz"))
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	file, err := os.CreateTemp(u.Dir, edition+"-*.tar.gz")
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if _, err = io.Copy(io.MultiWriter(file, hash), resp.Body); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != checksum {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", edition, checksum, sum)
+	}
+
+	return file.Name(), nil
+}
+
+// extract finds the edition's mmdb file in the tarball and writes it to a temporary file.
+func (u *Updater) extract(edition, tarball string) (string, error) {
+	file, err := os.Open(tarball)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+
+	for {
+		header, err := reader.Next()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		if header.Typeflag != tar.TypeReg || filepath.Base(header.Name) != edition+".mmdb" {
+			continue
+		}
+
+		out, err := os.CreateTemp(u.Dir, edition+"-*.mmdb.new")
+
+		if err != nil {
+			return "", err
+		}
+
+		_, err = io.Copy(out, reader)
+		out.Close()
+
+		if err != nil {
+			os.Remove(out.Name())
+			return "", err
+		}
+
+		return out.Name(), nil
+	}
+
+	return "", fmt.Errorf("the archive of %s doesn't contain %s.mmdb", edition, edition)
+}
+
+// validate opens the database to make sure it's a readable mmdb file of the expected edition.
+func validate(edition, path string) (*maxminddb.Metadata, error) {
+	reader, err := maxminddb.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	metadata := reader.Metadata
+
+	if metadata.DatabaseType != edition {
+		return nil, fmt.Errorf("expected a %s database, but got %q", edition, metadata.DatabaseType)
+	}
+
+	if metadata.NodeCount == 0 {
+		return nil, fmt.Errorf("the %s database is empty", edition)
+	}
+
+	return &metadata, nil
+}
+
+// Installs returns true if the file at path is where the updater installs one of its editions.
+func (u *Updater) Installs(path string) bool {
+	abs, err := filepath.Abs(path)
+
+	if err != nil {
+		return false
+	}
+
+	for _, edition := range u.Editions {
+		installed, err := filepath.Abs(filepath.Join(u.Dir, edition+".mmdb"))
+
+		if err == nil && installed == abs {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backupDir returns the folder where the previous versions are kept.
+func (u *Updater) backupDir() string {
+	return filepath.Join(u.Dir, "previous")
+}
+
+// backups lists the previous versions of an edition, newest first.
+func (u *Updater) backups(edition string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(u.backupDir(), edition+"_*.mmdb"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The names end in the build date, so they sort chronologically.
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+
+	return matches, nil
+}
+
+// backupName returns the name that a version of an edition is backed up under, which ends in its
+// build date.
+func backupName(edition, path string) string {
+	builtAt := time.Now()
+
+	if reader, err := maxminddb.Open(path); err == nil {
+		builtAt = time.Unix(int64(reader.Metadata.BuildEpoch), 0)
+		reader.Close()
+	}
+
+	return fmt.Sprintf("%s_%s.mmdb", edition, builtAt.UTC().Format("20060102150405"))
+}
+
+// backup copies the currently installed version of an edition to the backup folder, named after
+// its build date, and prunes the versions beyond the ones we keep.
+func (u *Updater) backup(edition string) error {
+	current := filepath.Join(u.Dir, edition+".mmdb")
+
+	if _, err := os.Stat(current); os.IsNotExist(err) {
+		return nil
+	}
+
+	if u.Keep <= 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(u.backupDir(), 0755); err != nil {
+		return err
+	}
+
+	// Copy instead of moving, so that the edition is never missing from the folder.
+	if err := copyFile(current, filepath.Join(u.backupDir(), backupName(edition, current))); err != nil {
+		return err
+	}
+
+	backups, err := u.backups(edition)
+
+	if err != nil {
+		return err
+	}
+
+	for i := u.Keep; i < len(backups); i++ {
+		os.Remove(backups[i])
+	}
+
+	return nil
+}
+
+// install replaces the installed version of an edition with the given file. The rename is atomic,
+// so a running server either sees the old or the new file.
+func (u *Updater) install(edition, path string) error {
+	if err := u.backup(edition); err != nil {
+		return err
+	}
+
+	return os.Rename(path, filepath.Join(u.Dir, edition+".mmdb"))
+}
+
+// UpdateEdition downloads, verifies, validates, and installs the latest version of an edition.
+func (u *Updater) UpdateEdition(edition string) error {
+	tarball, err := u.download(edition)
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tarball)
+
+	mmdb, err := u.extract(edition, tarball)
+
+	if err != nil {
+		return err
+	}
+
+	metadata, err := validate(edition, mmdb)
+
+	if err != nil {
+		os.Remove(mmdb)
+		return err
+	}
+
+	if err = u.install(edition, mmdb); err != nil {
+		os.Remove(mmdb)
+		return err
+	}
+
+	builtAt := time.Unix(int64(metadata.BuildEpoch), 0).UTC()
+	log.Printf("Installed %s (built on %s)\n", edition, builtAt.Format(time.RFC3339))
+
+	return nil
+}
+
+// Update updates every configured edition. It goes through all of them even if one fails, and
+// returns the first error.
+func (u *Updater) Update() error {
+	var firstErr error
+
+	if err := os.MkdirAll(u.Dir, 0755); err != nil {
+		return err
+	}
+
+	for _, edition := range u.Editions {
+		if err := u.UpdateEdition(edition); err != nil {
+			log.Printf("Unable to update %s: %s\n", edition, err)
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// Rollback reinstalls the most recent version of an edition that is older than the installed one.
+// The installed version is backed up first and the backups are kept, so rolling back again goes
+// one version further, and the newer versions can still be reinstalled by hand.
+func (u *Updater) Rollback(edition string) error {
+	backups, err := u.backups(edition)
+
+	if err != nil {
+		return err
+	}
+
+	current := filepath.Join(u.Dir, edition+".mmdb")
+	installed := ""
+
+	if _, err := os.Stat(current); err == nil {
+		installed = backupName(edition, current)
+	}
+
+	// The backups are sorted newest first, and their names sort by build date.
+	previous := ""
+
+	for _, backup := range backups {
+		if len(installed) == 0 || filepath.Base(backup) < installed {
+			previous = backup
+			break
+		}
+	}
+
+	if len(previous) == 0 {
+		return fmt.Errorf("there are no previous versions of %s", edition)
+	}
+
+	if _, err = validate(edition, previous); err != nil {
+		return err
+	}
+
+	if len(installed) > 0 {
+		if err = os.MkdirAll(u.backupDir(), 0755); err != nil {
+			return err
+		}
+
+		if err = copyFile(current, filepath.Join(u.backupDir(), installed)); err != nil {
+			return err
+		}
+	}
+
+	// The backup is copied next to the installed version first, so that the rename is atomic.
+	tmp := current + ".rollback"
+
+	if err = copyFile(previous, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, current); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	log.Printf("Rolled back %s to %s\n", edition, filepath.Base(previous))
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := os.Create(dst)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -3,37 +3,32 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"net"
 	"os"
 	"path/filepath"
-	"reflect"
 	"regexp"
 	"strings"
 
 	"github.com/wisepythagoras/geoip-service/extension"
 )
 
-func sliceContains[T any](arr []T, thing T) bool {
-	for _, v := range arr {
-		if reflect.DeepEqual(v, thing) {
-			return true
-		}
+// withDefaultDNSPort adds the default DNS port to a server's address if it doesn't have one.
+func withDefaultDNSPort(server string) string {
+	if !strings.Contains(server, ":") {
+		return fmt.Sprintf("%s:53", server)
 	}
 
-	return false
+	return server
 }
 
-// ParseIPList parses a list of IP addresses and CIDR ranges into two lists which could be used
-// as a whitelist or blacklist.
-func ParseIPList(file *os.File) ([]*net.IPNet, []net.IP, error) {
+// ParseDNSServerList parses a file which has a DNS server on each line. The format is:
+// 1.1.1.1:53 for the DNS server. Comments are allowed on the same line preceeded by #.
+func ParseDNSServerList(file *os.File) ([]string, error) {
 	scanner := bufio.NewScanner(file)
-	ipRanges := []*net.IPNet{}
-	ipAddresses := []net.IP{
-		net.ParseIP("127.0.0.1"),
-	}
+	dnsServerList := []string{}
 
 	for scanner.Scan() {
 		line := scanner.Text()
+
 		re := regexp.MustCompile(`(#(?:[^\n]+)?)`)
 		line = strings.Trim(re.ReplaceAllLiteralString(line, ""), " ")
 
@@ -41,33 +36,17 @@ func ParseIPList(file *os.File) ([]*net.IPNet, []net.IP, error) {
 			continue
 		}
 
-		isCidrRange := strings.Contains(line, "/")
-
-		if isCidrRange {
-			_, ipRange, err := net.ParseCIDR(line)
-
-			if err != nil {
-				return nil, nil, err
-			}
-
-			ipRanges = append(ipRanges, ipRange)
-		} else {
-			ipAddresses = append(ipAddresses, net.ParseIP(line))
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, nil, err
+		dnsServerList = append(dnsServerList, withDefaultDNSPort(line))
 	}
 
-	return ipRanges, ipAddresses, nil
+	return dnsServerList, nil
 }
 
-// ParseDNSServerList parses a file which has a DNS server on each line. The format is:
-// 1.1.1.1:53 for the DNS server. Comments are allowed on the same line preceeded by #.
-func ParseDNSServerList(file *os.File) ([]string, error) {
+// ParseSubjectList parses a file which has a certificate subject (e.g. CN=ops,O=Example) or just
+// a common name on each line. Comments are allowed on the same line preceeded by #.
+func ParseSubjectList(file *os.File) ([]string, error) {
 	scanner := bufio.NewScanner(file)
-	dnsServerList := []string{}
+	subjects := []string{}
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -79,14 +58,14 @@ func ParseDNSServerList(file *os.File) ([]string, error) {
 			continue
 		}
 
-		if !strings.Contains(line, ":") {
-			line = fmt.Sprintf("%s:53", line)
-		}
+		subjects = append(subjects, line)
+	}
 
-		dnsServerList = append(dnsServerList, line)
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	return dnsServerList, nil
+	return subjects, nil
 }
 
 func fileExists(path string) bool {